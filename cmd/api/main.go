@@ -2,8 +2,21 @@ package main
 
 import (
 	"log"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/jobs"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/mailer"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/notifications"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/oidc"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/pubsub"
 	"github.com/AgusMolinaCode/restApi-Go.git/internal/routes"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/security"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/services"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/webhooks"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/whatsapp"
 	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -15,7 +28,31 @@ func main() {
 		log.Println("No .env file found, using environment variables")
 	}
 
+	if os.Getenv("GIN_MODE") == "release" && os.Getenv("APP_BASE_URL") == "" {
+		log.Fatal("APP_BASE_URL is required in release mode")
+	}
+
+	security.InitKeys()
+	security.InitUnsubscribeSecret()
 	database.InitDB()
+	pubsub.Init()
+	security.InitLoginGuard()
+	security.InitPasswordPolicy()
+	if err := models.PromoteAdmins(strings.Split(os.Getenv("ADMIN_EMAILS"), ",")); err != nil {
+		log.Fatalf("Error promoting admins: %v", err)
+	}
+	oidc.Init()
+	services.InitWeather()
+	services.InitGeocoder()
+	mailer.Init()
+	whatsapp.Init()
+	notifications.RegisterHandlers()
+	webhooks.Init()
+	jobs.Start()
+	services.StartAccountErasure(time.Hour)
+	services.StartWeatherAlerts(time.Hour)
+	services.StartScheduledPublishing(time.Minute)
+	services.StartEventArchiving(time.Hour)
 	server := gin.Default()
 
 	routes.RegisterRoutes(server)
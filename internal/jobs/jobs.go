@@ -0,0 +1,197 @@
+// Package jobs implementa una cola de trabajos persistida en Postgres (patrón outbox): los trabajos
+// se encolan en la misma transacción que el cambio que los origina y un pool de workers los ejecuta.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/google/uuid"
+)
+
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusDead    = "dead"
+)
+
+const defaultMaxAttempts = 8
+
+type Job struct {
+	ID             string          `json:"id"`
+	Type           string          `json:"type"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	Sensitive      bool            `json:"sensitive"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	MaxAttempts    int             `json:"max_attempts"`
+	RunAt          time.Time       `json:"run_at"`
+	LastError      string          `json:"last_error,omitempty"`
+	IdempotencyKey string          `json:"idempotency_key,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	CompletedAt    *time.Time      `json:"completed_at,omitempty"`
+}
+
+type NewJob struct {
+	Type    string
+	Payload interface{}
+	// RunAt en cero significa lo antes posible
+	RunAt time.Time
+	// Si ya existe un trabajo con la misma clave no se encola otro
+	IdempotencyKey string
+	MaxAttempts    int
+	// Sensitive borra el payload al completarse, p. ej. si contiene enlaces con tokens
+	Sensitive bool
+}
+
+// Handler procesa un trabajo. Puede ejecutarse más de una vez para el mismo trabajo
+// (reintentos o caída del worker), así que debe ser idempotente; job.ID sirve como clave.
+type Handler func(ctx context.Context, job *Job) error
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]Handler{}
+)
+
+func Register(jobType string, handler Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[jobType] = handler
+}
+
+func handlerFor(jobType string) Handler {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	return handlers[jobType]
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marca un error que no se arregla reintentando: el trabajo pasa directo a dead
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// Enqueue guarda el trabajo usando exec, normalmente la transacción del cambio que lo origina,
+// para que el trabajo exista si y solo si el cambio se confirma
+func Enqueue(exec database.Executor, job NewJob) error {
+	payload, err := json.Marshal(job.Payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	runAt := job.RunAt
+	if runAt.IsZero() {
+		runAt = now
+	}
+	maxAttempts := job.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	query := `
+		INSERT INTO jobs (id, type, payload, status, attempts, max_attempts, run_at, idempotency_key, sensitive, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, 0, $5, $6, NULLIF($7, ''), $8, $9, $9)
+		ON CONFLICT (idempotency_key) DO NOTHING
+	`
+	_, err = exec.Exec(query, uuid.New().String(), job.Type, payload, StatusPending, maxAttempts, runAt, job.IdempotencyKey, job.Sensitive, now)
+	return err
+}
+
+const jobColumns = `id, type, payload, sensitive, status, attempts, max_attempts, run_at, COALESCE(last_error, ''), COALESCE(idempotency_key, ''), created_at, updated_at, completed_at`
+
+func scanJob(row interface {
+	Scan(dest ...interface{}) error
+}) (*Job, error) {
+	var job Job
+	var completedAt sql.NullTime
+	err := row.Scan(&job.ID, &job.Type, &job.Payload, &job.Sensitive, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt, &job.LastError, &job.IdempotencyKey, &job.CreatedAt, &job.UpdatedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+	return &job, nil
+}
+
+// Redacted devuelve el trabajo sin el payload si es sensible, para mostrarlo en el panel de administración.
+// El payload se borra recién al completarse, así que los pendientes y muertos todavía tienen los tokens.
+func (j Job) Redacted() Job {
+	if j.Sensitive {
+		j.Payload = nil
+	}
+	return j
+}
+
+func List(status, jobType string, page, limit int) ([]Job, int, error) {
+	filter := `WHERE ($1 = '' OR status = $1) AND ($2 = '' OR type = $2)`
+
+	var total int
+	err := database.DB.QueryRow(`SELECT COUNT(*) FROM jobs `+filter, status, jobType).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + jobColumns + ` FROM jobs ` + filter + ` ORDER BY created_at DESC LIMIT $3 OFFSET $4`
+	rows, err := database.DB.Query(query, status, jobType, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	return jobs, total, rows.Err()
+}
+
+func Get(id string) (*Job, error) {
+	row := database.DB.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id)
+	job, err := scanJob(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+var ErrNotRetryable = errors.New("only dead jobs can be retried")
+
+// Retry vuelve a poner en cola un trabajo muerto con el contador de intentos a cero
+func Retry(id string) (*Job, error) {
+	query := `UPDATE jobs SET status = $1, attempts = 0, run_at = $2, locked_until = NULL, updated_at = $2 WHERE id = $3 AND status = $4 RETURNING ` + jobColumns
+	job, err := scanJob(database.DB.QueryRow(query, StatusPending, time.Now(), id, StatusDead))
+	if err == nil {
+		return job, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	existing, err := Get(id)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w (job is %s)", ErrNotRetryable, existing.Status)
+}
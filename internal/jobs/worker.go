@@ -0,0 +1,161 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+const (
+	// Tiempo que un worker retiene un trabajo; si se cae, otro lo retoma al vencer
+	leaseDuration  = 5 * time.Minute
+	handlerTimeout = 4 * time.Minute
+
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+
+	// Los trabajos completados se borran pasado este tiempo
+	doneRetention = 7 * 24 * time.Hour
+)
+
+// Start lanza JOB_WORKERS workers (4 por defecto) que consultan la cola cada JOB_POLL_INTERVAL
+func Start() {
+	workers := 4
+	if value, err := strconv.Atoi(os.Getenv("JOB_WORKERS")); err == nil && value > 0 {
+		workers = value
+	}
+	pollInterval := 2 * time.Second
+	if value, err := time.ParseDuration(os.Getenv("JOB_POLL_INTERVAL")); err == nil && value > 0 {
+		pollInterval = value
+	}
+
+	for i := 0; i < workers; i++ {
+		go work(pollInterval)
+	}
+	go cleanup()
+
+	log.Printf("Started %d job workers", workers)
+}
+
+func work(pollInterval time.Duration) {
+	for {
+		processed, err := RunNext()
+		if err != nil {
+			log.Printf("Job worker error: %v", err)
+		}
+		// Mientras haya trabajos se sigue sin esperar
+		if !processed || err != nil {
+			time.Sleep(pollInterval)
+		}
+	}
+}
+
+// RunNext toma el siguiente trabajo disponible y lo ejecuta; false si la cola está vacía.
+// FOR UPDATE SKIP LOCKED permite varios workers, también en varias réplicas, sin pisarse.
+func RunNext() (bool, error) {
+	now := time.Now()
+	query := `
+		UPDATE jobs SET status = $1, attempts = attempts + 1, locked_until = $2, updated_at = $3
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE (status = $4 AND run_at <= $3) OR (status = $1 AND locked_until < $3)
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+	job, err := scanJob(database.DB.QueryRow(query, StatusRunning, now.Add(leaseDuration), now, StatusPending))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, finish(job, run(job))
+}
+
+func run(job *Job) (err error) {
+	handler := handlerFor(job.Type)
+	if handler == nil {
+		return Permanent(fmt.Errorf("no handler registered for job type %q", job.Type))
+	}
+
+	// Un panic en un handler no debe tirar el worker
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("handler panic: %v", recovered)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), handlerTimeout)
+	defer cancel()
+	return handler(ctx, job)
+}
+
+// finish guarda el resultado solo si el trabajo sigue siendo de este worker: si el lease venció y otro
+// worker lo retomó, attempts ya no coincide y el resultado de este intento se descarta
+func finish(job *Job, runErr error) error {
+	now := time.Now()
+
+	var result sql.Result
+	var err error
+	var permanent permanentError
+	switch {
+	case runErr == nil:
+		query := `UPDATE jobs SET status = $1, locked_until = NULL, last_error = NULL, completed_at = $2, updated_at = $2,
+			payload = CASE WHEN sensitive THEN '{}'::jsonb ELSE payload END
+			WHERE id = $3 AND status = $4 AND attempts = $5`
+		result, err = database.DB.Exec(query, StatusDone, now, job.ID, StatusRunning, job.Attempts)
+	case errors.As(runErr, &permanent) || job.Attempts >= job.MaxAttempts:
+		log.Printf("Job %s (%s) failed permanently after %d attempts: %v", job.ID, job.Type, job.Attempts, runErr)
+		query := `UPDATE jobs SET status = $1, locked_until = NULL, last_error = $2, updated_at = $3 WHERE id = $4 AND status = $5 AND attempts = $6`
+		result, err = database.DB.Exec(query, StatusDead, runErr.Error(), now, job.ID, StatusRunning, job.Attempts)
+	default:
+		query := `UPDATE jobs SET status = $1, locked_until = NULL, last_error = $2, run_at = $3, updated_at = $4 WHERE id = $5 AND status = $6 AND attempts = $7`
+		result, err = database.DB.Exec(query, StatusPending, runErr.Error(), now.Add(backoff(job.Attempts)), now, job.ID, StatusRunning, job.Attempts)
+	}
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		log.Printf("Job %s (%s) lease expired before attempt %d finished; result discarded", job.ID, job.Type, job.Attempts)
+	}
+	return nil
+}
+
+// backoff crece exponencialmente desde baseBackoff, con jitter para no reintentar todos a la vez
+func backoff(attempts int) time.Duration {
+	delay := maxBackoff
+	if attempts < 20 {
+		delay = baseBackoff << (attempts - 1)
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay) / 5))
+	return delay - delay/10 + jitter
+}
+
+func cleanup() {
+	for {
+		_, err := database.DB.Exec(`DELETE FROM jobs WHERE status = $1 AND completed_at < $2`, StatusDone, time.Now().Add(-doneRetention))
+		if err != nil {
+			log.Printf("Failed to clean up finished jobs: %v", err)
+		}
+		time.Sleep(time.Hour)
+	}
+}
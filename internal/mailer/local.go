@@ -0,0 +1,103 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// FileMailer guarda cada email como un archivo .eml que se puede abrir con cualquier cliente de correo
+type FileMailer struct {
+	Dir  string
+	From mail.Address
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	body, err := msg.Build(m.From, now)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102-150405.000000000"), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	return os.WriteFile(filepath.Join(m.Dir, name), body, 0o644)
+}
+
+type SentMessage struct {
+	Message
+	SentAt time.Time
+}
+
+// MemoryMailer guarda los emails en memoria; sirve para tests y para el buzón de desarrollo
+type MemoryMailer struct {
+	From mail.Address
+
+	mu       sync.Mutex
+	messages []SentMessage
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) error {
+	// Se arma igual que para SMTP para detectar los mismos errores
+	if _, err := msg.Build(m.From, time.Now()); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, SentMessage{Message: msg, SentAt: time.Now()})
+	return nil
+}
+
+func (m *MemoryMailer) Messages() []SentMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]SentMessage(nil), m.messages...)
+}
+
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
+
+// ServeHTTP lista los emails enviados (GET, filtrables con ?to=) o vacía el buzón (DELETE)
+func (m *MemoryMailer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		to := r.URL.Query().Get("to")
+		messages := []map[string]interface{}{}
+		for _, sent := range m.Messages() {
+			if to != "" && sent.To != to {
+				continue
+			}
+			messages = append(messages, map[string]interface{}{
+				"to":      sent.To,
+				"subject": sent.Subject,
+				"text":    sent.Text,
+				"html":    sent.HTML,
+				"headers": sent.Headers,
+				"sent_at": sent.SentAt,
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"messages": messages})
+	case http.MethodDelete:
+		m.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
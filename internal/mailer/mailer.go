@@ -0,0 +1,106 @@
+// Package mailer envía emails transaccionales a partir de plantillas con variante HTML y texto.
+package mailer
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/mail"
+	"os"
+	"strconv"
+)
+
+type Message struct {
+	// ID estable para el Message-ID; permite a los clientes descartar un mismo email reenviado
+	ID      string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	// Cabeceras adicionales, p. ej. List-Unsubscribe
+	Headers map[string]string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Default es el mailer configurado con MAIL_DRIVER
+var Default Mailer
+
+// Mailbox expone los emails enviados en /dev/mailbox cuando MAIL_DRIVER=memory
+var Mailbox http.Handler
+
+func Init() {
+	from := mail.Address{Name: os.Getenv("MAIL_FROM_NAME"), Address: envOr("MAIL_FROM", os.Getenv("EMAIL_FROM"))}
+	password := envOr("SMTP_PASSWORD", os.Getenv("EMAIL_PASSWORD"))
+
+	driver := os.Getenv("MAIL_DRIVER")
+	if driver == "" {
+		// Sin credenciales SMTP los emails se guardan en disco para poder revisarlos en desarrollo
+		driver = "file"
+		if password != "" {
+			driver = "smtp"
+		}
+	}
+
+	switch driver {
+	case "smtp":
+		port, err := strconv.Atoi(envOr("SMTP_PORT", "587"))
+		if err != nil {
+			log.Fatalf("Invalid SMTP_PORT: %v", err)
+		}
+		tlsMode := os.Getenv("SMTP_TLS")
+		if tlsMode == "" {
+			tlsMode = TLSStartTLS
+			if port == 465 {
+				tlsMode = TLSImplicit
+			}
+		}
+		if tlsMode != TLSStartTLS && tlsMode != TLSImplicit && tlsMode != TLSNone {
+			log.Fatalf("Unknown SMTP_TLS %q (use starttls, tls or none)", tlsMode)
+		}
+		Default = &SMTPMailer{
+			Host:     envOr("SMTP_HOST", "smtp.gmail.com"),
+			Port:     port,
+			Username: envOr("SMTP_USERNAME", from.Address),
+			Password: password,
+			TLS:      tlsMode,
+			From:     from,
+		}
+	case "file":
+		if os.Getenv("GIN_MODE") == "release" {
+			log.Fatal("MAIL_DRIVER=file cannot be used in release mode; configure SMTP")
+		}
+		dir := envOr("MAIL_DIR", "tmp/mail")
+		Default = &FileMailer{Dir: dir, From: from}
+		log.Printf("Emails will be written to %s", dir)
+	case "memory":
+		if os.Getenv("GIN_MODE") == "release" {
+			log.Fatal("MAIL_DRIVER=memory cannot be used in release mode")
+		}
+		memory := &MemoryMailer{From: from}
+		Default = memory
+		Mailbox = memory
+		log.Printf("Emails will be kept in memory and listed at /dev/mailbox")
+	default:
+		log.Fatalf("Unknown MAIL_DRIVER %q (use smtp, file or memory)", driver)
+	}
+}
+
+// SendTemplate renderiza la plantilla en el idioma del destinatario y la envía con Default
+func SendTemplate(ctx context.Context, to, locale, name string, data interface{}) error {
+	msg, err := Render(name, locale, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return Default.Send(ctx, msg)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,109 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+// DefaultLocale se usa cuando el usuario no eligió idioma o el pedido no está soportado
+const DefaultLocale = "es"
+
+var SupportedLocales = []string{"es", "en"}
+
+// Cada plantilla tiene, por idioma, un <nombre>.txt que define además el bloque "subject"
+// y un <nombre>.html que define el bloque "content" dentro de layout.html
+//
+//go:embed templates
+var templateFS embed.FS
+
+func Render(name, locale string, data interface{}) (Message, error) {
+	locale = NormalizeLocale(locale)
+
+	textTemplate, err := texttemplate.ParseFS(templateFS, path.Join("templates", locale, name+".txt"), path.Join("templates", locale, "footer.txt"))
+	if err != nil {
+		return Message{}, fmt.Errorf("mailer: unknown template %q: %w", name, err)
+	}
+	var subject, text bytes.Buffer
+	if err := textTemplate.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := textTemplate.Execute(&text, data); err != nil {
+		return Message{}, err
+	}
+	body := strings.TrimSpace(text.String()) + "\n"
+
+	unsubscribeURL := unsubscribeURLFrom(data)
+	if unsubscribeURL != "" {
+		var footer bytes.Buffer
+		if err := textTemplate.ExecuteTemplate(&footer, "footer", unsubscribeURL); err != nil {
+			return Message{}, err
+		}
+		body += "\n" + strings.TrimSpace(footer.String()) + "\n"
+	}
+
+	htmlTemplate, err := htmltemplate.ParseFS(templateFS, "templates/layout.html", path.Join("templates", locale, name+".html"), path.Join("templates", locale, "footer.html"))
+	if err != nil {
+		return Message{}, fmt.Errorf("mailer: unknown template %q: %w", name, err)
+	}
+	var html bytes.Buffer
+	err = htmlTemplate.ExecuteTemplate(&html, "layout.html", map[string]interface{}{
+		"Locale":  locale,
+		"Subject": strings.TrimSpace(subject.String()),
+		"Data":    data,
+		// El pie con el enlace para darse de baja solo va en los avisos que tienen tipo
+		"UnsubscribeURL": unsubscribeURL,
+	})
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    body,
+		HTML:    html.String(),
+	}, nil
+}
+
+// unsubscribeURLFrom lee la clave UnsubscribeURL de los datos de la plantilla, si son un mapa
+func unsubscribeURLFrom(data interface{}) string {
+	values, ok := data.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	url, _ := values["UnsubscribeURL"].(string)
+	return url
+}
+
+func IsSupportedLocale(locale string) bool {
+	for _, supported := range SupportedLocales {
+		if locale == supported {
+			return true
+		}
+	}
+	return false
+}
+
+func NormalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if IsSupportedLocale(locale) {
+		return locale
+	}
+	return DefaultLocale
+}
+
+// MatchLocale elige el primer idioma soportado de una cabecera Accept-Language
+func MatchLocale(acceptLanguage string) string {
+	for _, entry := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if IsSupportedLocale(primary) {
+			return primary
+		}
+	}
+	return DefaultLocale
+}
@@ -0,0 +1,261 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/mailer"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/notifications"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/utils"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/gin-gonic/gin"
+)
+
+func PatchMe(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var patch models.UserPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if patch.Username != nil && strings.TrimSpace(*patch.Username) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username cannot be empty"})
+		return
+	}
+	if patch.Locale != nil && !mailer.IsSupportedLocale(*patch.Locale) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale", "supported": mailer.SupportedLocales})
+		return
+	}
+
+	applyUserPatch(c, userID.(string), patch)
+}
+
+func applyUserPatch(c *gin.Context, userID string, patch models.UserPatch) {
+	if patch.IsEmpty() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+		return
+	}
+
+	// Un valor vacío borra el número; cualquier otro tiene que ser E.164
+	if patch.Whatsapp != nil && *patch.Whatsapp != "" {
+		whatsapp, valid := utils.NormalizeE164(*patch.Whatsapp)
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "whatsapp must be an international number in E.164 format, e.g. +5491122334455"})
+			return
+		}
+		patch.Whatsapp = &whatsapp
+	}
+
+	if err := models.PatchUser(userID, patch); err != nil {
+		if models.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "WhatsApp number already in use"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user", "details": err.Error()})
+		return
+	}
+
+	user, err := models.GetUserByID(userID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "user": user})
+}
+
+func UpdateNotificationChannels(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var request struct {
+		Channels []string `json:"channels" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := models.GetUserByID(userID.(string))
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Se eliminan duplicados conservando el orden
+	seen := map[string]bool{}
+	channels := []string{}
+	for _, channel := range request.Channels {
+		if !notifications.IsChannel(channel) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification channel: " + channel})
+			return
+		}
+		if channel == notifications.ChannelWhatsapp && user.Whatsapp == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Add a WhatsApp number to your profile before enabling WhatsApp notifications"})
+			return
+		}
+		if !seen[channel] {
+			seen[channel] = true
+			channels = append(channels, channel)
+		}
+	}
+
+	if err := models.UpdateNotificationChannels(user.ID, channels); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification channels", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification channels updated", "notification_channels": channels})
+}
+
+func ChangePassword(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var request struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := models.GetUserPasswordHash(userID.(string))
+	if err != nil || hashedPassword == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := models.VerifyPassword(hashedPassword, request.CurrentPassword); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	user, err := models.GetUserByID(userID.(string))
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if rejectWeakPassword(c, request.NewPassword, user.Email, user.Username) {
+		return
+	}
+
+	if err := models.UpdatePassword(userID.(string), request.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
+}
+
+func RequestEmailChange(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var request struct {
+		NewEmail string `json:"new_email" binding:"required,email"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := models.GetUserPasswordHash(userID.(string))
+	if err != nil || hashedPassword == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := models.VerifyPassword(hashedPassword, request.Password); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	existingUser, err := models.GetUserByEmail(request.NewEmail)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email", "details": err.Error()})
+		return
+	}
+	if existingUser != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+		return
+	}
+
+	user, err := models.GetUserByID(userID.(string))
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err = database.WithTx(func(tx *sql.Tx) error {
+		token, err := models.SetEmailChangeToken(tx, user.ID, request.NewEmail)
+		if err != nil {
+			return err
+		}
+
+		// El enlace se envía a la nueva dirección para comprobar que el usuario la controla
+		return notifications.SendEmail(tx, notifications.Email{
+			To:        request.NewEmail,
+			Locale:    user.Locale,
+			Template:  "email_change_confirm",
+			Data:      gin.H{"Link": utils.PublicURL("/confirm-email?token=" + token)},
+			Sensitive: true,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start email change", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Confirmation link sent to the new email address"})
+}
+
+func ConfirmEmailChange(c *gin.Context) {
+	var request struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var userID string
+	err := database.WithTx(func(tx *sql.Tx) error {
+		var oldEmail, newEmail string
+		var err error
+		userID, oldEmail, newEmail, err = models.ConfirmEmailChange(tx, request.Token)
+		if err != nil || userID == "" {
+			return err
+		}
+
+		// Avisar a la dirección anterior por si el cambio no fue del titular
+		return notifications.SendEmail(tx, notifications.Email{
+			To:       oldEmail,
+			UserID:   userID,
+			Template: "email_changed",
+			Data:     gin.H{"NewEmail": newEmail},
+		})
+	})
+	if err != nil {
+		if models.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm email change", "details": err.Error()})
+		return
+	}
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email updated successfully"})
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/security"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func CreateAPIKey(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var request struct {
+		Name   string   `json:"name" binding:"required"`
+		Scopes []string `json:"scopes" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := security.ValidateScopes(request.Scopes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "allowed_scopes": security.APIKeyScopes})
+		return
+	}
+
+	key, prefix, keyHash, err := security.GenerateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key", "details": err.Error()})
+		return
+	}
+
+	apiKey := models.APIKey{
+		ID:     uuid.New().String(),
+		UserID: userID.(string),
+		Name:   request.Name,
+		Prefix: prefix,
+		Scopes: request.Scopes,
+	}
+
+	if err := apiKey.Save(keyHash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key", "details": err.Error()})
+		return
+	}
+
+	// La clave completa solo se devuelve en esta respuesta
+	c.JSON(http.StatusCreated, gin.H{"message": "API key created, store it now because it won't be shown again", "key": key, "api_key": apiKey})
+}
+
+func GetAPIKeys(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	keys, err := models.GetAPIKeysByUserID(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve API keys", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func RevokeAPIKey(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	keyID := c.Param("keyId")
+
+	revoked, err := models.RevokeAPIKey(keyID, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key", "details": err.Error()})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -1,19 +1,34 @@
 package middleware
 
 import (
+	"database/sql"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
-	"os"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/mailer"
 	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/notifications"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/security"
 	"github.com/AgusMolinaCode/restApi-Go.git/internal/utils"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET"))
+const accessTokenTTL = 12 * time.Hour
+
+// El claim login_method indica cómo inició sesión el usuario, para pedir la confirmación adecuada
+const (
+	loginMethodPassword = "password"
+	loginMethodOIDC     = "oidc"
+)
 
 func Signup(c *gin.Context) {
 	var user models.User
@@ -22,6 +37,27 @@ func Signup(c *gin.Context) {
 		return
 	}
 
+	if rejectWeakPassword(c, user.Password, user.Email, user.Username) {
+		return
+	}
+
+	if user.Whatsapp != "" {
+		whatsapp, valid := utils.NormalizeE164(user.Whatsapp)
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "whatsapp must be an international number in E.164 format, e.g. +5491122334455"})
+			return
+		}
+		user.Whatsapp = whatsapp
+	}
+
+	// Sin idioma explícito se usa el del navegador para los emails
+	if user.Locale == "" {
+		user.Locale = mailer.MatchLocale(c.GetHeader("Accept-Language"))
+	} else if !mailer.IsSupportedLocale(user.Locale) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale", "supported": mailer.SupportedLocales})
+		return
+	}
+
 	user.ID = uuid.New().String()
 
 	if err := user.Save(); err != nil {
@@ -43,24 +79,50 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if rejectIfLocked(c, loginData.Email) {
+		return
+	}
+
 	user, err := models.GetUserByEmail(loginData.Email)
 	if err != nil || user == nil {
+		registerLoginFailure(c, loginData.Email, false)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
 	if err := models.VerifyPassword(user.Password, loginData.Password); err != nil {
+		registerLoginFailure(c, user.Email, true)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	// Generar token JWT
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(time.Hour * 12).Unix(),
-	})
+	// Quien recuerda su contraseña ya no necesita un enlace de recuperación pendiente
+	if err := models.ClearResetToken(user.ID); err != nil {
+		log.Printf("Failed to clear reset token: %v", err)
+	}
+
+	if !user.TOTPEnabled {
+		if err := security.Guard.RegisterSuccess(user.Email); err != nil {
+			log.Printf("Failed to reset login attempts: %v", err)
+		}
+	}
+
+	respondWithSession(c, user.ID, loginMethodPassword, user.TOTPEnabled)
+}
+
+// respondWithSession emite el JWT, o un desafío 2FA si el usuario lo tiene activo
+func respondWithSession(c *gin.Context, userID, loginMethod string, totpEnabled bool) {
+	if totpEnabled {
+		mfaToken, err := generateMFAToken(userID, loginMethod)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": mfaToken})
+		return
+	}
 
-	tokenString, err := token.SignedString(jwtKey)
+	tokenString, err := generateToken(userID, loginMethod)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -69,9 +131,95 @@ func Login(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"token": tokenString})
 }
 
+// rejectIfLocked responde 429 si la cuenta o la IP están bloqueadas temporalmente
+func rejectIfLocked(c *gin.Context, email string) bool {
+	retryAfter, err := security.Guard.Check(email, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts", "details": err.Error()})
+		return true
+	}
+	if retryAfter <= 0 {
+		return false
+	}
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, try again later", "retry_after": seconds})
+	return true
+}
+
+// rejectWeakPassword aplica la política de contraseñas y responde con todas las reglas incumplidas
+func rejectWeakPassword(c *gin.Context, password string, personalInfo ...string) bool {
+	violations := security.Passwords.Validate(password, personalInfo...)
+	if len(violations) == 0 {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Password does not meet the security policy", "violations": violations})
+	return true
+}
+
+// registerLoginFailure suma el fallo y avisa al titular cuando la cuenta queda bloqueada
+func registerLoginFailure(c *gin.Context, email string, accountExists bool) {
+	accountLocked, retryAfter, err := security.Guard.RegisterFailure(email, c.ClientIP())
+	if err != nil {
+		log.Printf("Failed to register login attempt: %v", err)
+		return
+	}
+
+	if accountLocked && accountExists {
+		sendLockoutEmail(email, retryAfter)
+	}
+}
+
+func sendLockoutEmail(email string, retryAfter time.Duration) {
+	err := notifications.SendEmail(database.DB, notifications.Email{
+		To:       email,
+		Template: "account_locked",
+		Data:     gin.H{"RetryAfter": retryAfter.Round(time.Second).String()},
+		// Varios fallos simultáneos no deben generar varios avisos
+		IdempotencyKey: fmt.Sprintf("account_locked:%s:%d", strings.ToLower(email), time.Now().Truncate(time.Minute).Unix()),
+	})
+	if err != nil {
+		log.Printf("Failed to enqueue lockout email: %v", err)
+	}
+}
+
+func generateToken(userID, loginMethod string) (string, error) {
+	return security.Keys.Issue(jwt.MapClaims{
+		"sub":          userID,
+		"user_id":      userID,
+		"login_method": loginMethod,
+	}, accessTokenTTL)
+}
+
+// generateMFAToken emite un token de corta duración que solo sirve para completar el login con 2FA
+func generateMFAToken(userID, loginMethod string) (string, error) {
+	return security.Keys.Issue(jwt.MapClaims{
+		"sub":          userID,
+		"user_id":      userID,
+		"type":         mfaTokenType,
+		"login_method": loginMethod,
+	}, mfaTokenTTL)
+}
+
 func GetUserByID(c *gin.Context) {
 	id := c.Param("id")
-	user, err := models.GetUserByID(id)
+	profile, err := models.GetPublicProfile(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user", "details": err.Error()})
+		return
+	}
+	if profile == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+func GetMe(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	user, err := models.GetUserByID(userID.(string))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user", "details": err.Error()})
 		return
@@ -84,12 +232,49 @@ func GetUserByID(c *gin.Context) {
 }
 
 func GetAllUsers(c *gin.Context) {
-	users, err := models.GetAllUsers()
+	page, limit := PaginationParams(c, 20, 100)
+
+	users, total, err := models.SearchUsers(c.Query("q"), page, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users", "details": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, gin.H{"users": users, "page": page, "limit": limit, "total": total})
+}
+
+func UpdatePrivacySettings(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var settings models.PrivacySettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.UpdatePrivacySettings(userID.(string), settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update privacy settings", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Privacy settings updated", "privacy": settings})
+}
+
+// PaginationParams lee page y limit de la query con valores por defecto y un límite máximo
+func PaginationParams(c *gin.Context, defaultLimit, maxLimit int) (int, int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	return page, limit
 }
 
 func UpdateUserByID(c *gin.Context) {
@@ -107,28 +292,32 @@ func UpdateUserByID(c *gin.Context) {
 		return
 	}
 
-	// Obtener el usuario actual para preservar los valores existentes
 	existingUser, err := models.GetUserByID(id)
 	if err != nil || existingUser == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	// Validar que whatsapp y email no estén vacíos
-	if updatedUser.Whatsapp == "" {
-		updatedUser.Whatsapp = existingUser.Whatsapp
+	// La contraseña y el email se cambian con sus flujos dedicados
+	if updatedUser.Password != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use POST /users/me/password to change the password"})
+		return
 	}
-	if updatedUser.Email == "" {
-		updatedUser.Email = existingUser.Email
+	if updatedUser.Email != "" && updatedUser.Email != existingUser.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use POST /users/me/email to change the email"})
+		return
 	}
 
-	err = models.UpdateUserByID(id, updatedUser)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user", "details": err.Error()})
-		return
+	// Los campos vacíos conservan el valor actual
+	var patch models.UserPatch
+	if updatedUser.Username != "" {
+		patch.Username = &updatedUser.Username
+	}
+	if updatedUser.Whatsapp != "" {
+		patch.Whatsapp = &updatedUser.Whatsapp
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+	applyUserPatch(c, id, patch)
 }
 
 func DeleteUserByID(c *gin.Context) {
@@ -140,13 +329,28 @@ func DeleteUserByID(c *gin.Context) {
 		return
 	}
 
-	err := models.DeleteUserByID(id)
+	// Igual que en RequestErasure, borrar la cuenta requiere confirmar la identidad
+	var request struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !confirmAccountOwner(c, id, request.Password, request.Code) {
+		return
+	}
+
+	// El borrado se programa con periodo de gracia; ver RequestErasure
+	erasure, err := models.ScheduleErasure(id, "", erasureGracePeriod())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user", "details": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	c.JSON(http.StatusAccepted, gin.H{"message": "User scheduled for deletion", "erasure": erasure})
 }
 
 func ForgotPassword(c *gin.Context) {
@@ -159,24 +363,38 @@ func ForgotPassword(c *gin.Context) {
 		return
 	}
 
-	token, err := models.SetResetToken(request.Email)
+	allowed, err := security.Guard.AllowPasswordReset(request.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate reset token", "details": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check rate limit", "details": err.Error()})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many password reset requests, try again later"})
 		return
 	}
 
-	// Construir el enlace de restablecimiento de contraseña
-	resetLink := fmt.Sprintf("https://restapi-go-production.up.railway.app/reset-password?token=%s", token)
-
-	// Enviar el correo electrónico
-	subject := "Password Reset Request"
-	body := fmt.Sprintf("Click the link to reset your password: %s", resetLink)
-	if err := utils.SendEmail(request.Email, subject, body); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send email", "details": err.Error()})
+	// El token y el email se guardan juntos: si falla uno no queda el otro
+	err = database.WithTx(func(tx *sql.Tx) error {
+		token, err := models.SetResetToken(tx, request.Email)
+		if err != nil || token == "" {
+			return err
+		}
+
+		return notifications.SendEmail(tx, notifications.Email{
+			To:        request.Email,
+			Template:  "password_reset",
+			Data:      gin.H{"Link": utils.PublicURL("/reset-password?token=" + url.QueryEscape(token))},
+			Sensitive: true,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate reset token", "details": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password reset token sent to email"})
+	// Misma respuesta exista o no la cuenta; el envío lo hace la cola en segundo plano,
+	// así que tampoco se puede distinguir por el tiempo de respuesta
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for that email, a password reset link has been sent"})
 }
 
 func ResetPassword(c *gin.Context) {
@@ -196,11 +414,25 @@ func ResetPassword(c *gin.Context) {
 		return
 	}
 
-	err = models.UpdatePassword(userID, request.NewPassword)
+	user, err := models.GetUserByID(userID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+
+	if rejectWeakPassword(c, request.NewPassword, user.Email, user.Username) {
+		return
+	}
+
+	reset, err := models.ResetPasswordWithToken(request.Token, request.NewPassword)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password", "details": err.Error()})
 		return
 	}
+	if !reset {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
 }
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/jobs"
+	"github.com/gin-gonic/gin"
+)
+
+func GetJobs(c *gin.Context) {
+	page, limit := PaginationParams(c, 50, 200)
+
+	list, total, err := jobs.List(c.Query("status"), c.Query("type"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs", "details": err.Error()})
+		return
+	}
+
+	for i := range list {
+		list[i] = list[i].Redacted()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": list, "page": page, "limit": limit, "total": total})
+}
+
+func GetJob(c *gin.Context) {
+	job, err := jobs.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job", "details": err.Error()})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job.Redacted())
+}
+
+func RetryJob(c *gin.Context) {
+	job, err := jobs.Retry(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, jobs.ErrNotRetryable) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry job", "details": err.Error()})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Job queued for retry", "job": job.Redacted()})
+}
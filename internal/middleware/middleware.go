@@ -1,16 +1,29 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/security"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	authMethodJWT    = "jwt"
+	authMethodAPIKey = "api_key"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Las integraciones servidor a servidor se autentican con una API key
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, apiKey)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -18,28 +31,136 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
+		tokenString, found := strings.CutPrefix(authHeader, "Bearer ")
+		if !found || tokenString == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
 
-		if err != nil || !token.Valid {
+		// Verifica firma, algoritmo, kid, exp, iss y aud
+		claims, err := security.Keys.Parse(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		// Los tokens de desafío 2FA no dan acceso a rutas protegidas
+		if claims["type"] == mfaTokenType {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Two-factor authentication required"})
 			c.Abort()
 			return
 		}
 
-		userID := claims["user_id"].(string)
+		userID, ok := claims["user_id"].(string)
+		if !ok || userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			c.Abort()
+			return
+		}
 		c.Set("userID", userID)
+		c.Set("authMethod", authMethodJWT)
+		// Para confirmar acciones irreversibles con un login reciente
+		if loginMethod, ok := claims["login_method"].(string); ok {
+			c.Set("loginMethod", loginMethod)
+		}
+		if issuedAt, ok := claims["iat"].(float64); ok {
+			c.Set("loggedInAt", time.Unix(int64(issuedAt), 0))
+		}
 
 		c.Next()
 	}
 }
+
+// OptionalAuth identifica al usuario si manda credenciales, para rutas públicas que muestran más
+// a su dueño (p. ej. los borradores); sin credenciales deja pasar la petición como anónima
+func OptionalAuth() gin.HandlerFunc {
+	auth := AuthMiddleware()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" && c.GetHeader("X-API-Key") == "" {
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
+
+func authenticateAPIKey(c *gin.Context, apiKey string) {
+	key, err := models.GetActiveAPIKeyByHash(security.HashAPIKey(apiKey))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify API key", "details": err.Error()})
+		c.Abort()
+		return
+	}
+	if key == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		c.Abort()
+		return
+	}
+
+	if err := models.TouchAPIKey(key.ID); err != nil {
+		log.Printf("Failed to update API key last use: %v", err)
+	}
+
+	c.Set("userID", key.UserID)
+	c.Set("authMethod", authMethodAPIKey)
+	c.Set("apiKeyScopes", key.Scopes)
+
+	c.Next()
+}
+
+// RequireScope limita lo que puede hacer una API key; las sesiones JWT tienen todos los permisos
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") != authMethodAPIKey {
+			c.Next()
+			return
+		}
+
+		for _, granted := range c.GetStringSlice("apiKeyScopes") {
+			if granted == scope {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing the required scope", "scope": scope})
+		c.Abort()
+	}
+}
+
+// RequireSession restringe la ruta a usuarios con sesión (no API keys), p. ej. la gestión de la cuenta
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") != authMethodJWT {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This action requires a user session"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdmin consulta el rol en la base de datos para que los cambios apliquen al instante
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, err := models.GetUserRole(c.GetString("userID"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions", "details": err.Error()})
+			c.Abort()
+			return
+		}
+		if role != models.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": security.Keys.JWKS()})
+}
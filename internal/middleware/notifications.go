@@ -0,0 +1,260 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/notifications"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/pubsub"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/security"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/utils"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/gin-gonic/gin"
+)
+
+func GetNotificationPreferences(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	matrix, ok := notificationPreferenceMatrix(c, userID.(string))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preferences": matrix})
+}
+
+// UpdateNotificationPreferences recibe solo las combinaciones que cambian,
+// p. ej. {"preferences": {"reminders": {"whatsapp": true, "email": false}}}
+func UpdateNotificationPreferences(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var request struct {
+		Preferences map[string]map[string]bool `json:"preferences" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recipient, err := models.GetRecipient(database.DB, userID.(string))
+	if err != nil || recipient == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var preferences []models.NotificationPreference
+	for notificationType, channels := range request.Preferences {
+		if !notifications.IsType(notificationType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification type: " + notificationType, "types": notifications.Types})
+			return
+		}
+		for channel, enabled := range channels {
+			if !notifications.IsPreferenceChannel(channel) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification channel: " + channel, "channels": notifications.PreferenceChannels})
+				return
+			}
+			if channel == notifications.ChannelWhatsapp && enabled && recipient.Whatsapp == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Add a WhatsApp number to your profile before enabling WhatsApp notifications"})
+				return
+			}
+			preferences = append(preferences, models.NotificationPreference{Type: notificationType, Channel: channel, Enabled: enabled})
+		}
+	}
+
+	if err := models.SetNotificationPreferences(recipient.UserID, preferences); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences", "details": err.Error()})
+		return
+	}
+
+	matrix, ok := notificationPreferenceMatrix(c, recipient.UserID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification preferences updated", "preferences": matrix})
+}
+
+func notificationPreferenceMatrix(c *gin.Context, userID string) (map[string]map[string]bool, bool) {
+	recipient, err := models.GetRecipient(database.DB, userID)
+	if err != nil || recipient == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+
+	preferences, err := models.GetNotificationPreferences(database.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notification preferences", "details": err.Error()})
+		return nil, false
+	}
+
+	return notifications.PreferenceMatrix(*recipient, preferences), true
+}
+
+// GetUnsubscribe describe de qué se da de baja el enlace, para que la página de la aplicación lo muestre
+// antes de confirmar; no cambia nada porque los filtros de correo abren los enlaces por su cuenta
+func GetUnsubscribe(c *gin.Context) {
+	_, notificationType, channel, err := security.ParseUnsubscribeToken(c.Query("token"))
+	if err != nil || !notifications.IsType(notificationType) || !notifications.IsPreferenceChannel(channel) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unsubscribe link"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"type": notificationType, "channel": channel})
+}
+
+// Unsubscribe da de baja sin iniciar sesión. Es también el destino de la cabecera List-Unsubscribe,
+// al que los clientes de correo hacen un POST con el cuerpo List-Unsubscribe=One-Click (RFC 8058).
+func Unsubscribe(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		token = c.PostForm("token")
+	}
+
+	userID, notificationType, channel, err := security.ParseUnsubscribeToken(token)
+	if err != nil || !notifications.IsType(notificationType) || !notifications.IsPreferenceChannel(channel) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unsubscribe link"})
+		return
+	}
+
+	recipient, err := models.GetRecipient(database.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe", "details": err.Error()})
+		return
+	}
+
+	// Si la cuenta ya no existe no hay nada que dar de baja
+	if recipient != nil {
+		preference := models.NotificationPreference{Type: notificationType, Channel: channel, Enabled: false}
+		if err := models.SetNotificationPreferences(userID, []models.NotificationPreference{preference}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe", "details": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed successfully", "type": notificationType, "channel": channel})
+}
+
+func GetMyNotifications(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	page, limit := PaginationParams(c, 20, 100)
+
+	items, total, err := models.GetNotifications(userID.(string), c.Query("unread") == "true", page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications", "details": err.Error()})
+		return
+	}
+
+	unread, err := models.CountUnreadNotifications(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": items,
+		"unread_count":  unread,
+		"page":          page,
+		"limit":         limit,
+		"total":         total,
+	})
+}
+
+func MarkNotificationRead(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	found, err := models.MarkNotificationRead(userID.(string), c.Param("notificationId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notification as read", "details": err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	respondUnreadCount(c, userID.(string), "Notification marked as read")
+}
+
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if _, err := models.MarkAllNotificationsRead(userID.(string)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications as read", "details": err.Error()})
+		return
+	}
+
+	respondUnreadCount(c, userID.(string), "All notifications marked as read")
+}
+
+func respondUnreadCount(c *gin.Context, userID, message string) {
+	unread, err := models.CountUnreadNotifications(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "unread_count": unread})
+}
+
+// StreamNotifications envía por Server-Sent Events el contador de no leídas al conectarse y cada
+// notificación nueva (evento "notification", con su ID como id del evento). Al reconectarse con
+// Last-Event-ID se reenvían las que se perdieron mientras tanto.
+func StreamNotifications(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	// Suscribirse antes de leer la base para no perder lo que llegue en el medio
+	messages, cancel := pubsub.Default.Subscribe(notifications.InboxTopic(userID))
+	defer cancel()
+
+	var missed []models.UserNotification
+	if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" {
+		var err error
+		missed, err = models.GetNotificationsAfter(userID, lastEventID, 100)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications", "details": err.Error()})
+			return
+		}
+	}
+
+	unread, err := models.CountUnreadNotifications(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications", "details": err.Error()})
+		return
+	}
+
+	utils.StartSSE(c.Writer)
+
+	sent := map[string]bool{}
+	utils.WriteSSE(c.Writer, "", "unread_count", gin.H{"unread_count": unread})
+	for _, item := range missed {
+		utils.WriteSSE(c.Writer, item.ID, "notification", item)
+		sent[item.ID] = true
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(utils.SSEKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			utils.WriteSSEKeepAlive(c.Writer)
+			c.Writer.Flush()
+		case payload, ok := <-messages:
+			if !ok {
+				return
+			}
+			var item models.UserNotification
+			if err := json.Unmarshal(payload, &item); err != nil || sent[item.ID] {
+				continue
+			}
+			utils.WriteSSE(c.Writer, item.ID, "notification", json.RawMessage(payload))
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func OIDCLogin(c *gin.Context) {
+	if oidc.Default == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	state, err := newOIDCState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login", "details": err.Error()})
+		return
+	}
+
+	if err := models.SaveOIDCState(*state); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login", "details": err.Error()})
+		return
+	}
+
+	authURL, err := oidc.Default.AuthCodeURL(c.Request.Context(), state.State, state.Nonce, oidc.CodeChallenge(state.CodeVerifier))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable", "details": err.Error()})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func OIDCCallback(c *gin.Context) {
+	if oidc.Default == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Identity provider rejected the login", "details": providerError})
+		return
+	}
+
+	code, stateParam := c.Query("code"), c.Query("state")
+	if code == "" || stateParam == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+
+	state, err := models.ConsumeOIDCState(stateParam)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify login state", "details": err.Error()})
+		return
+	}
+	if state == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tokens, err := oidc.Default.Exchange(ctx, code, state.CodeVerifier)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to exchange authorization code", "details": err.Error()})
+		return
+	}
+
+	claims, err := oidc.Default.VerifyIDToken(ctx, tokens.IDToken, state.Nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid identity token"})
+		return
+	}
+
+	userID, err := resolveOIDCUser(claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link account", "details": err.Error()})
+		return
+	}
+	if userID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The identity provider did not return a verified email"})
+		return
+	}
+
+	twoFactor, err := models.GetTwoFactorState(userID)
+	if err != nil || twoFactor == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+
+	respondWithSession(c, userID, loginMethodOIDC, twoFactor.Enabled)
+}
+
+func newOIDCState() (*models.OIDCState, error) {
+	state, err := oidc.RandomToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := oidc.RandomToken()
+	if err != nil {
+		return nil, err
+	}
+	codeVerifier, err := oidc.RandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OIDCState{State: state, Nonce: nonce, CodeVerifier: codeVerifier}, nil
+}
+
+// resolveOIDCUser busca la cuenta vinculada a la identidad externa. Si no existe, la vincula
+// a un usuario con el mismo email (solo si el proveedor lo verificó) o crea uno nuevo.
+func resolveOIDCUser(claims *oidc.IDTokenClaims) (string, error) {
+	userID, err := models.GetUserIDByIdentity(claims.Issuer, claims.Subject)
+	if err != nil || userID != "" {
+		return userID, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return "", nil
+	}
+
+	identity := models.UserIdentity{
+		ID:      uuid.New().String(),
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		Email:   claims.Email,
+	}
+
+	existingUser, err := models.GetUserByEmail(claims.Email)
+	if err != nil {
+		return "", err
+	}
+	if existingUser != nil {
+		identity.UserID = existingUser.ID
+		if err := identity.Save(); err != nil {
+			return "", err
+		}
+		return existingUser.ID, nil
+	}
+
+	username := claims.Name
+	if username == "" {
+		username = strings.Split(claims.Email, "@")[0]
+	}
+
+	if err := models.CreateUserWithIdentity(username, &identity); err != nil {
+		return "", err
+	}
+	return identity.UserID, nil
+}
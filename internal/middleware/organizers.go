@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+func GetOrganizer(c *gin.Context) {
+	profile, err := models.GetOrganizerProfile(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizer", "details": err.Error()})
+		return
+	}
+	if profile == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organizer not found"})
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+func GetOrganizerEvents(c *gin.Context) {
+	profile, err := models.GetOrganizerProfile(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizer", "details": err.Error()})
+		return
+	}
+	if profile == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organizer not found"})
+		return
+	}
+
+	events, err := models.GetEventSummariesByUserID(profile.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"organizer": profile, "events": events})
+}
+
+// UpdateOrganizerProfile reemplaza el perfil de organizador; los campos vacíos vuelven a los del usuario
+func UpdateOrganizerProfile(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var input models.OrganizerProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	input.Bio = strings.TrimSpace(input.Bio)
+	input.LogoURL = strings.TrimSpace(input.LogoURL)
+	if len(input.Bio) > 2000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bio cannot exceed 2000 characters"})
+		return
+	}
+	// Se muestran en una página pública: solo enlaces http(s)
+	if input.LogoURL != "" && !isHTTPURL(input.LogoURL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "logo_url must be an http or https URL"})
+		return
+	}
+	for network, link := range input.SocialLinks {
+		if !isHTTPURL(link) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "social_links must be http or https URLs", "network": network})
+			return
+		}
+	}
+
+	if err := models.SaveOrganizerProfile(userID, input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organizer profile", "details": err.Error()})
+		return
+	}
+
+	profile, err := models.GetOrganizerProfile(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizer", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+func isHTTPURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func FollowOrganizer(c *gin.Context) {
+	userID := c.GetString("userID")
+	organizerID := c.Param("id")
+	if organizerID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot follow yourself"})
+		return
+	}
+
+	profile, err := models.GetOrganizerProfile(organizerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizer", "details": err.Error()})
+		return
+	}
+	if profile == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organizer not found"})
+		return
+	}
+
+	if err := models.Follow(userID, organizerID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow organizer", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Following organizer"})
+}
+
+func UnfollowOrganizer(c *gin.Context) {
+	if err := models.Unfollow(c.GetString("userID"), c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow organizer", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Unfollowed organizer"})
+}
+
+// GetFollowStatus indica si el usuario autenticado sigue al organizador
+func GetFollowStatus(c *gin.Context) {
+	following, err := models.IsFollowing(c.GetString("userID"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check follow", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"following": following})
+}
+
+func GetFollowing(c *gin.Context) {
+	organizers, err := models.GetFollowedOrganizers(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve followed organizers", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"organizers": organizers})
+}
+
+// SetOrganizerVerification da o quita la insignia de organizador verificado
+func SetOrganizerVerification(c *gin.Context) {
+	var input struct {
+		Verified *bool `json:"verified" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, err := models.GetOrganizerProfile(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizer", "details": err.Error()})
+		return
+	}
+	if profile == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organizer not found"})
+		return
+	}
+
+	if err := models.SetOrganizerVerified(profile.ID, *input.Verified); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update verification", "details": err.Error()})
+		return
+	}
+	profile.Verified = *input.Verified
+	c.JSON(http.StatusOK, profile)
+}
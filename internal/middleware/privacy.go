@@ -0,0 +1,257 @@
+package middleware
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultErasureGraceDays = 30
+
+// recentLoginWindow es el tiempo durante el que un login con el proveedor externo confirma la identidad
+const recentLoginWindow = 10 * time.Minute
+
+// confirmAccountOwner pide la contraseña antes de borrar la cuenta. Las cuentas creadas con un
+// proveedor externo tienen una contraseña aleatoria: también aceptan un código de 2FA o un login reciente con el proveedor.
+// Si la confirmación falla ya respondió al cliente.
+func confirmAccountOwner(c *gin.Context, userID, password, code string) bool {
+	if password != "" {
+		hashedPassword, err := models.GetUserPasswordHash(userID)
+		if err != nil || hashedPassword == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return false
+		}
+		if err := models.VerifyPassword(hashedPassword, password); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+			return false
+		}
+		return true
+	}
+
+	linked, err := models.HasLinkedIdentity(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check linked identities", "details": err.Error()})
+		return false
+	}
+	if !linked {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password is required"})
+		return false
+	}
+
+	if code != "" {
+		state, err := models.GetTwoFactorState(userID)
+		if err != nil || state == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+			return false
+		}
+		if !state.Enabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+			return false
+		}
+		ok, err := verifySecondFactor(userID, state, code)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code", "details": err.Error()})
+			return false
+		}
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+			return false
+		}
+		return true
+	}
+
+	if c.GetString("loginMethod") == loginMethodOIDC && time.Since(c.GetTime("loggedInAt")) <= recentLoginWindow {
+		return true
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Send your password or a two-factor code, or log in again with your identity provider"})
+	return false
+}
+
+func erasureGracePeriod() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"))
+	if err != nil || days < 0 {
+		days = defaultErasureGraceDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func ExportMyData(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	export, err := models.ExportUserData(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data", "details": err.Error()})
+		return
+	}
+	if export == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	// Por defecto se entrega un ZIP con un archivo JSON por cada tipo de dato
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"registrations.json", export.Registrations},
+		{"organized_events.json", export.OrganizedEvents},
+		{"api_keys.json", export.APIKeys},
+		{"linked_identities.json", export.LinkedIdentities},
+		{"notification_preferences.json", export.NotificationPreferences},
+		{"notifications.json", export.Notifications},
+		{"webhooks.json", export.Webhooks},
+		{"venues.json", export.Venues},
+		{"organizer_profile.json", export.OrganizerProfile},
+		{"following.json", export.Following},
+	}
+
+	filename := fmt.Sprintf("export-%s-%s.zip", userID, time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	for _, f := range files {
+		file, err := archive.Create(f.name)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(f.data); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		c.Error(err)
+	}
+}
+
+func RequestErasure(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var request struct {
+		Password         string `json:"password"`
+		Code             string `json:"code"`
+		TransferEventsTo string `json:"transfer_events_to"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !confirmAccountOwner(c, userID.(string), request.Password, request.Code) {
+		return
+	}
+
+	// Si se indica, los eventos pasan a otra cuenta en lugar de archivarse, siempre que esa cuenta
+	// acepte el pedido en POST /users/me/event-transfers/:userId/accept antes del borrado
+	if request.TransferEventsTo != "" {
+		if request.TransferEventsTo == userID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Events cannot be transferred to the same account"})
+			return
+		}
+		target, err := models.GetUserByID(request.TransferEventsTo)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check transfer target", "details": err.Error()})
+			return
+		}
+		if target == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Transfer target user not found"})
+			return
+		}
+	}
+
+	erasure, err := models.ScheduleErasure(userID.(string), request.TransferEventsTo, erasureGracePeriod())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule erasure", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Account scheduled for deletion", "erasure": erasure})
+}
+
+// GetEventTransfers lista las cuentas pendientes de borrado que pidieron pasarle sus eventos al usuario
+func GetEventTransfers(c *gin.Context) {
+	transfers, err := models.GetEventTransfers(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event transfers", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"transfers": transfers})
+}
+
+func AcceptEventTransfer(c *gin.Context) {
+	respondToEventTransfer(c, true)
+}
+
+func DeclineEventTransfer(c *gin.Context) {
+	respondToEventTransfer(c, false)
+}
+
+func respondToEventTransfer(c *gin.Context, accept bool) {
+	found, err := models.RespondToEventTransfer(c.Param("userId"), c.GetString("userID"), accept)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event transfer", "details": err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No pending event transfer from that user"})
+		return
+	}
+
+	if accept {
+		c.JSON(http.StatusOK, gin.H{"message": "Event transfer accepted"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Event transfer declined"})
+}
+
+func GetErasureStatus(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	erasure, err := models.GetErasureRequest(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve erasure request", "details": err.Error()})
+		return
+	}
+	if erasure == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No pending erasure request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, erasure)
+}
+
+func CancelErasure(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	cancelled, err := models.CancelErasure(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel erasure", "details": err.Error()})
+		return
+	}
+	if !cancelled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No pending erasure request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/security"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	mfaTokenType      = "mfa_challenge"
+	mfaTokenTTL       = 5 * time.Minute
+	totpIssuer        = "RestApi-Go Events"
+	recoveryCodeCount = 10
+)
+
+func SetupTwoFactor(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	user, err := models.GetUserByID(userID.(string))
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	state, err := models.GetTwoFactorState(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve two-factor state", "details": err.Error()})
+		return
+	}
+	if state.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret", "details": err.Error()})
+		return
+	}
+
+	if err := models.SetPendingTOTPSecret(user.ID, secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_url": utils.TOTPURL(totpIssuer, user.Email, secret),
+	})
+}
+
+func ConfirmTwoFactor(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var request struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	state, err := models.GetTwoFactorState(userID.(string))
+	if err != nil || state == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if state.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if state.Secret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor setup has not been started"})
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(state.Secret, request.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	recoveryCodes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes", "details": err.Error()})
+		return
+	}
+
+	if err := models.EnableTOTP(userID.(string), step, recoveryCodes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication", "details": err.Error()})
+		return
+	}
+
+	// Los códigos de recuperación solo se muestran una vez
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recovery_codes": recoveryCodes})
+}
+
+func DisableTwoFactor(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var request struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := models.GetUserPasswordHash(userID.(string))
+	if err != nil || hashedPassword == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := models.VerifyPassword(hashedPassword, request.Password); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	state, err := models.GetTwoFactorState(userID.(string))
+	if err != nil || state == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !state.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	ok, err := verifySecondFactor(userID.(string), state, request.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code", "details": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	if err := models.DisableTOTP(userID.(string)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func LoginTwoFactor(c *gin.Context) {
+	var request struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, loginMethod, err := parseMFAToken(request.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+
+	user, err := models.GetUserByID(userID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+
+	// Los códigos 2FA cuentan para el mismo límite de intentos que la contraseña
+	if rejectIfLocked(c, user.Email) {
+		return
+	}
+
+	state, err := models.GetTwoFactorState(userID)
+	if err != nil || state == nil || !state.Enabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+
+	ok, err := verifySecondFactor(userID, state, request.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code", "details": err.Error()})
+		return
+	}
+	if !ok {
+		registerLoginFailure(c, user.Email, true)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	if err := security.Guard.RegisterSuccess(user.Email); err != nil {
+		log.Printf("Failed to reset login attempts: %v", err)
+	}
+
+	tokenString, err := generateToken(userID, loginMethod)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+}
+
+// verifySecondFactor acepta un código TOTP no reutilizado o un código de recuperación
+func verifySecondFactor(userID string, state *models.TwoFactorState, code string) (bool, error) {
+	if step, ok := utils.ValidateTOTPAfter(state.Secret, code, time.Now(), state.LastStep); ok {
+		return models.MarkTOTPStepUsed(userID, step)
+	}
+
+	return models.UseRecoveryCode(userID, code)
+}
+
+// parseMFAToken devuelve el usuario del desafío y cómo inició sesión
+func parseMFAToken(tokenString string) (string, string, error) {
+	claims, err := security.Keys.Parse(tokenString)
+	if err != nil {
+		return "", "", err
+	}
+	if claims["type"] != mfaTokenType {
+		return "", "", security.ErrInvalidToken
+	}
+
+	userID, ok := claims["user_id"].(string)
+	if !ok || userID == "" {
+		return "", "", security.ErrInvalidToken
+	}
+	loginMethod, _ := claims["login_method"].(string)
+
+	return userID, loginMethod, nil
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/webhooks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func CreateWebhook(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var request struct {
+		URL        string   `json:"url" binding:"required"`
+		EventTypes []string `json:"event_types" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !validWebhookRequest(c, &request.URL, request.EventTypes) {
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate webhook secret", "details": err.Error()})
+		return
+	}
+
+	webhook := models.Webhook{
+		ID:         uuid.New().String(),
+		UserID:     userID.(string),
+		URL:        request.URL,
+		EventTypes: request.EventTypes,
+		Secret:     secret,
+	}
+
+	if err := webhook.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook", "details": err.Error()})
+		return
+	}
+
+	// El secreto solo se devuelve en esta respuesta
+	c.JSON(http.StatusCreated, gin.H{"message": "Webhook created, store the secret now because it won't be shown again", "webhook": webhook})
+}
+
+// validWebhookRequest valida la URL y los tipos de evento; responde el error y devuelve false si no son válidos
+func validWebhookRequest(c *gin.Context, url *string, eventTypes []string) bool {
+	if url != nil {
+		if err := webhooks.ValidateURL(*url); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+	}
+	for _, eventType := range eventTypes {
+		if !webhooks.IsEventType(eventType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown event type: " + eventType, "event_types": webhooks.EventTypes})
+			return false
+		}
+	}
+	return true
+}
+
+func GetWebhooks(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	list, err := models.GetWebhooksByUserID(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhooks", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": list, "event_types": webhooks.EventTypes})
+}
+
+// ownWebhook carga el webhook de la URL si es del usuario autenticado; si no, responde 404.
+// Incluye el secreto, así que hay que borrarlo antes de devolverlo.
+func ownWebhook(c *gin.Context) (*models.Webhook, bool) {
+	userID, _ := c.Get("userID")
+
+	webhook, err := models.GetWebhookByID(c.Param("webhookId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook", "details": err.Error()})
+		return nil, false
+	}
+	if webhook == nil || webhook.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+func GetWebhook(c *gin.Context) {
+	webhook, ok := ownWebhook(c)
+	if !ok {
+		return
+	}
+
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, gin.H{"webhook": webhook})
+}
+
+// UpdateWebhook cambia la URL, los tipos de evento o el estado; reactivarlo pone en cero los fallos
+func UpdateWebhook(c *gin.Context) {
+	webhook, ok := ownWebhook(c)
+	if !ok {
+		return
+	}
+
+	var patch models.WebhookPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if patch.URL == nil && patch.EventTypes == nil && patch.Active == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+		return
+	}
+	if patch.EventTypes != nil && len(patch.EventTypes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one event type is required"})
+		return
+	}
+	if !validWebhookRequest(c, patch.URL, patch.EventTypes) {
+		return
+	}
+
+	if err := models.UpdateWebhook(webhook.ID, patch); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook", "details": err.Error()})
+		return
+	}
+
+	updated, ok := ownWebhook(c)
+	if !ok {
+		return
+	}
+
+	updated.Secret = ""
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook updated successfully", "webhook": updated})
+}
+
+func DeleteWebhook(c *gin.Context) {
+	webhook, ok := ownWebhook(c)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteWebhook(webhook.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// TestWebhook encola una entrega "webhook.test" sin reintentos; el resultado aparece en el registro de entregas
+func TestWebhook(c *gin.Context) {
+	webhook, ok := ownWebhook(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := webhooks.SendTest(webhook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send test delivery", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Test delivery queued", "delivery": delivery})
+}
+
+func GetWebhookDeliveries(c *gin.Context) {
+	webhook, ok := ownWebhook(c)
+	if !ok {
+		return
+	}
+	page, limit := PaginationParams(c, 20, 100)
+
+	deliveries, total, err := models.GetWebhookDeliveries(webhook.ID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook deliveries", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries, "page": page, "limit": limit, "total": total})
+}
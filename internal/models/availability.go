@@ -0,0 +1,166 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+// DateAvailability es el estado de una fecha del evento según su cupo y las inscripciones
+type DateAvailability struct {
+	Date       string `json:"date"`
+	Time       string `json:"time"`
+	Status     string `json:"status"`
+	Capacity   int    `json:"capacity,omitempty"`
+	Registered int    `json:"registered"`
+	// Remaining es nil cuando la fecha no tiene cupo
+	Remaining *int `json:"remaining"`
+}
+
+// fewLeft indica si quedan pocos lugares: el 10% del cupo o menos, y al menos el último
+func fewLeft(remaining, capacity int) bool {
+	threshold := capacity / 10
+	if threshold < 1 {
+		threshold = 1
+	}
+	return remaining <= threshold
+}
+
+func dateAvailability(date string, dateTime DateTime, registered int) DateAvailability {
+	availability := DateAvailability{
+		Date:       date,
+		Time:       dateTime.Time,
+		Status:     dateTime.Status,
+		Capacity:   dateTime.Capacity,
+		Registered: registered,
+	}
+	if dateTime.Capacity <= 0 {
+		return availability
+	}
+
+	remaining := dateTime.Capacity - registered
+	if remaining < 0 {
+		remaining = 0
+	}
+	availability.Remaining = &remaining
+
+	// Si el organizador cerró la fecha a mano se respeta aunque quede cupo
+	switch {
+	case remaining == 0 || dateTime.Status == DateStatusSoldOut:
+		availability.Status = DateStatusSoldOut
+	case fewLeft(remaining, dateTime.Capacity):
+		availability.Status = DateStatusFewLeft
+	case availability.Status == "" || availability.Status == DateStatusFewLeft:
+		availability.Status = DateStatusAvailable
+	}
+
+	return availability
+}
+
+func CountRegistrationsByDate(exec database.Executor, eventID string) (map[string]int, error) {
+	rows, err := exec.Query(`SELECT COALESCE(event_date, ''), COUNT(*) FROM registrations WHERE event_id = $1 GROUP BY event_date`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var date string
+		var count int
+		if err := rows.Scan(&date, &count); err != nil {
+			return nil, err
+		}
+		counts[date] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// GetEventAvailability devuelve las fechas del evento ordenadas cronológicamente
+func GetEventAvailability(exec database.Executor, event *Event) ([]DateAvailability, error) {
+	counts, err := CountRegistrationsByDate(exec, event.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	availability := make([]DateAvailability, 0, len(event.DateTimes))
+	for date, dateTime := range event.DateTimes {
+		availability = append(availability, dateAvailability(date, dateTime, counts[date]))
+	}
+
+	sort.Slice(availability, func(i, j int) bool {
+		ti, okI := event.OccurrenceTime(availability[i].Date)
+		tj, okJ := event.OccurrenceTime(availability[j].Date)
+		if okI && okJ {
+			return ti.Before(tj)
+		}
+		return availability[i].Date < availability[j].Date
+	})
+
+	return availability, nil
+}
+
+// Resultados de ReserveSeat
+const (
+	SeatReserved           = "reserved"
+	SeatSoldOut            = "sold_out"
+	SeatEventNotFound      = "event_not_found"
+	SeatDateNotScheduled   = "date_not_scheduled"
+	SeatRegistrationClosed = "registration_closed"
+	SeatAlreadyRegistered  = "already_registered"
+)
+
+// ReserveSeat bloquea el evento dentro de la transacción y vuelve a comprobar el estado, la fecha,
+// la inscripción previa del usuario y el cupo, para que dos inscripciones simultáneas no puedan
+// ocupar el último lugar ni colarse tras una cancelación. Devuelve SeatReserved si se puede inscribir.
+func ReserveSeat(tx *sql.Tx, eventID, userID, date string) (string, error) {
+	var status string
+	var dateTimesJSON []byte
+	err := tx.QueryRow(`SELECT status, date_times FROM events WHERE id = $1 FOR UPDATE`, eventID).Scan(&status, &dateTimesJSON)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return SeatEventNotFound, nil
+		}
+		return "", err
+	}
+
+	if !(Event{Status: status}).AcceptsRegistrations() {
+		return SeatRegistrationClosed, nil
+	}
+
+	var dateTimes map[string]DateTime
+	if err := json.Unmarshal(dateTimesJSON, &dateTimes); err != nil {
+		return "", err
+	}
+	dateTime, ok := dateTimes[date]
+	if !ok {
+		return SeatDateNotScheduled, nil
+	}
+
+	var registered bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM registrations WHERE event_id = $1 AND user_id = $2)`, eventID, userID).Scan(&registered)
+	if err != nil {
+		return "", err
+	}
+	if registered {
+		return SeatAlreadyRegistered, nil
+	}
+
+	var count int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM registrations WHERE event_id = $1 AND event_date = $2`, eventID, date).Scan(&count)
+	if err != nil {
+		return "", err
+	}
+	if dateAvailability(date, dateTime, count).Status == DateStatusSoldOut {
+		return SeatSoldOut, nil
+	}
+
+	return SeatReserved, nil
+}
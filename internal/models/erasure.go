@@ -0,0 +1,415 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/jobs"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/lib/pq"
+)
+
+// UserDataExport reúne todos los datos personales de un usuario (derecho de acceso / portabilidad)
+type UserDataExport struct {
+	ExportedAt       string         `json:"exported_at"`
+	Profile          *UserResponse  `json:"profile"`
+	Registrations    []Registration `json:"registrations"`
+	OrganizedEvents  []Event        `json:"organized_events"`
+	APIKeys          []APIKey       `json:"api_keys"`
+	LinkedIdentities []UserIdentity `json:"linked_identities"`
+	// Preferencias de notificación que el usuario cambió, por tipo y canal
+	NotificationPreferences map[string]map[string]bool `json:"notification_preferences"`
+	Notifications           []UserNotification         `json:"notifications"`
+	Webhooks                []Webhook                  `json:"webhooks"`
+	Venues                  []Venue                    `json:"venues"`
+	OrganizerProfile        *OrganizerProfile          `json:"organizer_profile"`
+	Following               []OrganizerProfile         `json:"following"`
+}
+
+func ExportUserData(userID string) (*UserDataExport, error) {
+	profile, err := GetUserByID(userID)
+	if err != nil || profile == nil {
+		return nil, err
+	}
+
+	export := &UserDataExport{
+		ExportedAt: time.Now().Format(time.RFC3339),
+		Profile:    profile,
+	}
+
+	if export.Registrations, err = GetRegistrationsByUserID(userID); err != nil {
+		return nil, err
+	}
+	if export.OrganizedEvents, err = GetEventsByUserID(userID); err != nil {
+		return nil, err
+	}
+	if export.APIKeys, err = GetAPIKeysByUserID(userID); err != nil {
+		return nil, err
+	}
+	if export.LinkedIdentities, err = GetIdentitiesByUserID(userID); err != nil {
+		return nil, err
+	}
+	if export.NotificationPreferences, err = GetNotificationPreferences(database.DB, userID); err != nil {
+		return nil, err
+	}
+	export.Notifications, err = queryNotifications(`SELECT `+notificationColumns+` FROM notifications WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	if export.Webhooks, err = GetWebhooksByUserID(userID); err != nil {
+		return nil, err
+	}
+	if export.Venues, err = GetVenuesByUserID(userID); err != nil {
+		return nil, err
+	}
+	if export.OrganizerProfile, err = GetOrganizerProfile(userID); err != nil {
+		return nil, err
+	}
+	if export.Following, err = GetFollowedOrganizers(userID); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+func GetRegistrationsByUserID(userID string) ([]Registration, error) {
+	query := `SELECT id, event_id, user_id, COALESCE(whatsapp, ''), created_at, COALESCE(event_date, ''), COALESCE(payment_link, '') FROM registrations WHERE user_id = $1 ORDER BY created_at`
+	rows, err := database.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	registrations := []Registration{}
+	for rows.Next() {
+		var r Registration
+		err := rows.Scan(&r.ID, &r.EventID, &r.UserID, &r.Whatsapp, &r.CreatedAt, &r.EventDate, &r.PaymentLink)
+		if err != nil {
+			return nil, err
+		}
+		registrations = append(registrations, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return registrations, nil
+}
+
+// GetEventsByUserID incluye los eventos archivados: es la vista del propio organizador
+func GetEventsByUserID(userID string) ([]Event, error) {
+	query := `SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category, outdoor, weather_alerts_attendees, COALESCE(location_city, ''), COALESCE(location_province, ''), COALESCE(venue_id, ''), status, publish_at, COALESCE(status_reason, '') FROM events WHERE user_id = $1 ORDER BY created_at`
+	rows, err := database.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func scanEvent(row rowScanner) (*Event, error) {
+	var event Event
+	var paymentLinkJSON, scheduleJSON, dateTimesJSON, rulesJSON, socialLinksJSON, accessibilityJSON, additionalImagesJSON []byte
+	err := row.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category, &event.Outdoor, &event.WeatherAlertsAttendees, &event.Location.City, &event.Location.Province, &event.VenueID, &event.Status, &event.PublishAt, &event.StatusReason)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convertir JSON a mapas, manejando nulos
+	fields := []struct {
+		data   []byte
+		target interface{}
+	}{
+		{dateTimesJSON, &event.DateTimes},
+		{paymentLinkJSON, &event.PaymentLink},
+		{scheduleJSON, &event.Schedule},
+		{rulesJSON, &event.Rules},
+		{socialLinksJSON, &event.SocialLinks},
+		{accessibilityJSON, &event.Accessibility},
+		{additionalImagesJSON, &event.AdditionalImages},
+	}
+	for _, field := range fields {
+		if field.data == nil {
+			continue
+		}
+		if err := json.Unmarshal(field.data, field.target); err != nil {
+			return nil, err
+		}
+	}
+
+	return &event, nil
+}
+
+func GetIdentitiesByUserID(userID string) ([]UserIdentity, error) {
+	query := `SELECT id, user_id, issuer, subject, COALESCE(email, ''), created_at FROM user_identities WHERE user_id = $1`
+	rows, err := database.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []UserIdentity{}
+	for rows.Next() {
+		var identity UserIdentity
+		err := rows.Scan(&identity.ID, &identity.UserID, &identity.Issuer, &identity.Subject, &identity.Email, &identity.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+type ErasureRequest struct {
+	RequestedAt      time.Time `json:"requested_at"`
+	ScheduledFor     time.Time `json:"scheduled_for"`
+	TransferEventsTo string    `json:"transfer_events_to,omitempty"`
+	// TransferAccepted indica si la cuenta destino aceptó recibir los eventos; si no, se archivan
+	TransferAccepted bool `json:"transfer_accepted"`
+}
+
+// EventTransfer es un pedido de otra cuenta, pendiente de borrado, para pasarle sus eventos
+type EventTransfer struct {
+	FromUserID   string    `json:"from_user_id"`
+	FromUsername string    `json:"from_username"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+	Accepted     bool      `json:"accepted"`
+}
+
+// ScheduleErasure marca la cuenta para borrado definitivo al terminar el periodo de gracia
+func ScheduleErasure(userID, transferEventsTo string, grace time.Duration) (*ErasureRequest, error) {
+	now := time.Now()
+	request := &ErasureRequest{
+		RequestedAt:      now,
+		ScheduledFor:     now.Add(grace),
+		TransferEventsTo: transferEventsTo,
+	}
+
+	query := `UPDATE users SET deletion_requested_at = $1, deletion_scheduled_for = $2, deletion_transfer_to = NULLIF($3, ''), deletion_transfer_accepted_at = NULL WHERE id = $4`
+	_, err := database.DB.Exec(query, request.RequestedAt, request.ScheduledFor, transferEventsTo, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func GetErasureRequest(userID string) (*ErasureRequest, error) {
+	query := `SELECT deletion_requested_at, deletion_scheduled_for, COALESCE(deletion_transfer_to, ''), deletion_transfer_accepted_at IS NOT NULL FROM users WHERE id = $1 AND deletion_scheduled_for IS NOT NULL`
+	row := database.DB.QueryRow(query, userID)
+
+	var request ErasureRequest
+	err := row.Scan(&request.RequestedAt, &request.ScheduledFor, &request.TransferEventsTo, &request.TransferAccepted)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+func CancelErasure(userID string) (bool, error) {
+	query := `UPDATE users SET deletion_requested_at = NULL, deletion_scheduled_for = NULL, deletion_transfer_to = NULL, deletion_transfer_accepted_at = NULL WHERE id = $1 AND deletion_scheduled_for IS NOT NULL`
+	result, err := database.DB.Exec(query, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// GetEventTransfers son los pedidos de transferencia de eventos dirigidos al usuario
+func GetEventTransfers(userID string) ([]EventTransfer, error) {
+	query := `
+		SELECT id, username, deletion_scheduled_for, deletion_transfer_accepted_at IS NOT NULL
+		FROM users
+		WHERE deletion_transfer_to = $1 AND deletion_scheduled_for IS NOT NULL
+		ORDER BY deletion_scheduled_for
+	`
+	rows, err := database.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := []EventTransfer{}
+	for rows.Next() {
+		var t EventTransfer
+		if err := rows.Scan(&t.FromUserID, &t.FromUsername, &t.ScheduledFor, &t.Accepted); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+
+	return transfers, rows.Err()
+}
+
+// RespondToEventTransfer acepta o rechaza el pedido de fromUserID; al rechazarlo sus eventos se archivarán.
+// Devuelve false si no hay un pedido pendiente dirigido a toUserID.
+func RespondToEventTransfer(fromUserID, toUserID string, accept bool) (bool, error) {
+	query := `UPDATE users SET deletion_transfer_accepted_at = $1 WHERE id = $2 AND deletion_transfer_to = $3 AND deletion_scheduled_for IS NOT NULL`
+	args := []interface{}{time.Now(), fromUserID, toUserID}
+	if !accept {
+		query = `UPDATE users SET deletion_transfer_to = NULL, deletion_transfer_accepted_at = NULL WHERE id = $1 AND deletion_transfer_to = $2 AND deletion_scheduled_for IS NOT NULL`
+		args = args[1:]
+	}
+
+	result, err := database.DB.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func GetUsersDueForErasure(now time.Time) ([]string, error) {
+	query := `SELECT id FROM users WHERE deletion_scheduled_for IS NOT NULL AND deletion_scheduled_for <= $1`
+	rows, err := database.DB.Query(query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// EraseUser borra definitivamente la cuenta en una sola transacción: anonimiza sus inscripciones,
+// transfiere o archiva sus eventos y elimina el resto de datos personales
+func EraseUser(userID string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var email, transferTo string
+	// Los eventos solo se transfieren si la cuenta destino aceptó
+	query := `SELECT email, CASE WHEN deletion_transfer_accepted_at IS NOT NULL THEN COALESCE(deletion_transfer_to, '') ELSE '' END FROM users WHERE id = $1 AND deletion_scheduled_for <= $2 FOR UPDATE`
+	err = tx.QueryRow(query, userID, time.Now()).Scan(&email, &transferTo)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// La solicitud se canceló o el usuario ya no existe
+			return nil
+		}
+		return err
+	}
+
+	// Las inscripciones se conservan para las estadísticas del organizador, sin datos personales
+	_, err = tx.Exec(`UPDATE registrations SET user_id = NULL, whatsapp = NULL WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	if transferTo != "" {
+		// Solo se transfiere a una cuenta que exista y no esté pendiente de borrado
+		var exists bool
+		err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND deletion_scheduled_for IS NULL)`, transferTo).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			transferTo = ""
+		}
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	if transferTo != "" {
+		_, err = tx.Exec(`UPDATE events SET user_id = $1, updated_at = $2 WHERE user_id = $3`, transferTo, now, userID)
+	} else {
+		_, err = tx.Exec(`UPDATE events SET status = $1, archived_at = $2, updated_at = $2 WHERE user_id = $3 AND status <> $1`, EventStatusArchived, now, userID)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Los lugares siguen a los eventos que los usan: se transfieren o quedan sin dueño
+	_, err = tx.Exec(`UPDATE venues SET user_id = NULLIF($1, ''), updated_at = $2 WHERE user_id = $3`, transferTo, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+
+	normalizedEmail := strings.ToLower(strings.TrimSpace(email))
+
+	// Los avisos pendientes o fallidos guardan la dirección del usuario y los muertos no se purgan nunca
+	_, err = tx.Exec(`DELETE FROM jobs WHERE status = ANY($1) AND (payload->>'user_id' = $2 OR LOWER(payload->>'to') = $3)`,
+		pq.Array([]string{jobs.StatusPending, jobs.StatusDead}), userID, normalizedEmail)
+	if err != nil {
+		return err
+	}
+
+	// Los webhooks de otros organizadores conservan sus datos de contacto en las entregas registradas
+	_, err = tx.Exec(`UPDATE webhook_deliveries SET payload = jsonb_set(payload, '{data,attendee}', jsonb_build_object('user_id', NULL)) WHERE payload->'data'->'attendee'->>'user_id' = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	statements := []string{
+		`DELETE FROM recovery_codes WHERE user_id = $1`,
+		`DELETE FROM api_keys WHERE user_id = $1`,
+		`DELETE FROM user_identities WHERE user_id = $1`,
+		`DELETE FROM notification_preferences WHERE user_id = $1`,
+		`DELETE FROM notifications WHERE user_id = $1`,
+		`DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE user_id = $1)`,
+		`DELETE FROM webhooks WHERE user_id = $1`,
+		`DELETE FROM follows WHERE follower_id = $1 OR organizer_id = $1`,
+		`DELETE FROM organizer_profiles WHERE user_id = $1`,
+		`DELETE FROM users WHERE id = $1`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, userID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`DELETE FROM login_attempts WHERE key = ANY($1)`, pq.Array([]string{"account:" + normalizedEmail, "forgot:" + normalizedEmail}))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -3,6 +3,9 @@ package models
 import (
 	"database/sql"
 	"encoding/json"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
@@ -10,17 +13,35 @@ import (
 	"github.com/lib/pq"
 )
 
+// Location con lat y lng en 0 se considera sin coordenadas: se completan geocodificando la dirección.
+// City y Province se obtienen de las coordenadas y sirven para filtrar.
 type Location struct {
-	Address string  `json:"address" validate:"required"`
-	Lng     float64 `json:"lng" validate:"required"`
-	Lat     float64 `json:"lat" validate:"required"`
+	Address  string  `json:"address" validate:"required"`
+	Lng      float64 `json:"lng" validate:"longitude"`
+	Lat      float64 `json:"lat" validate:"latitude"`
+	City     string  `json:"city"`
+	Province string  `json:"province"`
+}
+
+// HasCoordinates indica si se cargaron coordenadas; (0, 0) está en el océano y se toma como vacío
+func (l Location) HasCoordinates() bool {
+	return l.Lat != 0 || l.Lng != 0
 }
 
 type DateTime struct {
 	Time   string `json:"time"`
 	Status string `json:"status"`
+	// Capacity es el cupo de la fecha; 0 significa sin límite y el estado lo maneja el organizador
+	Capacity int `json:"capacity,omitempty"`
 }
 
+// Estados de una fecha. Con cupo, "pocas unidades" y "agotado" se calculan a partir de las inscripciones.
+const (
+	DateStatusAvailable = "disponibles"
+	DateStatusFewLeft   = "pocas unidades"
+	DateStatusSoldOut   = "agotado"
+)
+
 type Event struct {
 	ID          string              `json:"id" validate:"required,uuid4"`
 	Name        string              `json:"name" validate:"required"`
@@ -46,12 +67,21 @@ type Event struct {
 	MainImageURL     string            `json:"main_image_url"`
 	AdditionalImages []string          `json:"additional_images"`
 	Category         string            `json:"category"`
+	// Outdoor activa las alertas de clima; WeatherAlertsAttendees las envía también a los inscriptos
+	Outdoor                bool `json:"outdoor"`
+	WeatherAlertsAttendees bool `json:"weather_alerts_attendees"`
+	// VenueID es el lugar del que se copian la ubicación y sus datos; vacío si la ubicación se cargó a mano
+	VenueID string `json:"venue_id,omitempty"`
+	// Status solo cambia con SetEventStatus; PublishAt es la publicación programada de un borrador
+	Status       string     `json:"status"`
+	PublishAt    *time.Time `json:"publish_at,omitempty"`
+	StatusReason string     `json:"status_reason,omitempty"`
 }
 
-func (e Event) Save() error {
+func (e Event) Save(exec database.Executor) error {
 	query := `
-		INSERT INTO events (id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+		INSERT INTO events (id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category, outdoor, weather_alerts_attendees, location_city, location_province, venue_id, status, publish_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, NULLIF($28, ''), $29, $30)
 	`
 
 	// Convertir el mapa de DateTimes a JSON
@@ -93,12 +123,12 @@ func (e Event) Save() error {
 		return err
 	}
 
-	_, err = database.DB.Exec(query, e.ID, e.Name, e.Description, e.Location.Address, e.Location.Lng, e.Location.Lat, dateTimesJSON, e.UserID, e.CreatedAt, e.UpdatedAt, paymentLinkJSON, pq.Array(e.Tags), e.TransportGuide, scheduleJSON, e.ExclusiveParking, e.MinPrice, rulesJSON, socialLinksJSON, accessibilityJSON, e.DeliveryMethod, e.MainImageURL, additionalImagesJSON, e.Category)
+	_, err = exec.Exec(query, e.ID, e.Name, e.Description, e.Location.Address, e.Location.Lng, e.Location.Lat, dateTimesJSON, e.UserID, e.CreatedAt, e.UpdatedAt, paymentLinkJSON, pq.Array(e.Tags), e.TransportGuide, scheduleJSON, e.ExclusiveParking, e.MinPrice, rulesJSON, socialLinksJSON, accessibilityJSON, e.DeliveryMethod, e.MainImageURL, additionalImagesJSON, e.Category, e.Outdoor, e.WeatherAlertsAttendees, e.Location.City, e.Location.Province, e.VenueID, e.Status, e.PublishAt)
 	return err
 }
 
 func GetAllEvents() ([]Event, error) {
-	query := `SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category FROM events`
+	query := `SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category, outdoor, weather_alerts_attendees, COALESCE(location_city, ''), COALESCE(location_province, ''), COALESCE(venue_id, ''), status, publish_at, COALESCE(status_reason, '') FROM events WHERE ` + publicEventFilter
 	rows, err := database.DB.Query(query)
 	if err != nil {
 		return nil, err
@@ -109,7 +139,7 @@ func GetAllEvents() ([]Event, error) {
 	for rows.Next() {
 		var event Event
 		var paymentLinkJSON, scheduleJSON, dateTimesJSON, rulesJSON, socialLinksJSON, accessibilityJSON, additionalImagesJSON []byte
-		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category)
+		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category, &event.Outdoor, &event.WeatherAlertsAttendees, &event.Location.City, &event.Location.Province, &event.VenueID, &event.Status, &event.PublishAt, &event.StatusReason)
 		if err != nil {
 			return nil, err
 		}
@@ -161,12 +191,21 @@ func GetAllEvents() ([]Event, error) {
 }
 
 func GetEventByID(id string) (*Event, error) {
-	query := `SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category FROM events WHERE id = $1`
-	row := database.DB.QueryRow(query, id)
+	return getEventByID(database.DB, id, "")
+}
+
+// LockEventByID lee el evento dentro de la transacción y lo bloquea hasta que termine
+func LockEventByID(tx *sql.Tx, id string) (*Event, error) {
+	return getEventByID(tx, id, " FOR UPDATE")
+}
+
+func getEventByID(q database.Executor, id, lock string) (*Event, error) {
+	query := `SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category, outdoor, weather_alerts_attendees, COALESCE(location_city, ''), COALESCE(location_province, ''), COALESCE(venue_id, ''), status, publish_at, COALESCE(status_reason, '') FROM events WHERE id = $1` + lock
+	row := q.QueryRow(query, id)
 
 	var event Event
 	var paymentLinkJSON, scheduleJSON, dateTimesJSON, rulesJSON, socialLinksJSON, accessibilityJSON, additionalImagesJSON []byte
-	err := row.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category)
+	err := row.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category, &event.Outdoor, &event.WeatherAlertsAttendees, &event.Location.City, &event.Location.Province, &event.VenueID, &event.Status, &event.PublishAt, &event.StatusReason)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -213,11 +252,11 @@ func GetEventByID(id string) (*Event, error) {
 	return &event, nil
 }
 
-func UpdateEventByID(id string, updatedEvent Event) error {
+func UpdateEventByID(exec database.Executor, id string, updatedEvent Event) error {
 	query := `
 		UPDATE events
-		SET name = $1, description = $2, location_address = $3, location_lng = $4, location_lat = $5, date_times = $6, user_id = $7, updated_at = $8, payment_link = $9, tags = $10, transport_guide = $11, schedule = $12, exclusive_parking = $13, min_price = $14, rules = $15, social_links = $16, accessibility = $17, delivery_method = $18, main_image_url = $19, additional_images = $20, category = $21
-		WHERE id = $22
+		SET name = $1, description = $2, location_address = $3, location_lng = $4, location_lat = $5, date_times = $6, user_id = $7, updated_at = $8, payment_link = $9, tags = $10, transport_guide = $11, schedule = $12, exclusive_parking = $13, min_price = $14, rules = $15, social_links = $16, accessibility = $17, delivery_method = $18, main_image_url = $19, additional_images = $20, category = $21, outdoor = $22, weather_alerts_attendees = $23, location_city = $24, location_province = $25, venue_id = NULLIF($26, '')
+		WHERE id = $27
 	`
 	// Convertir el mapa de PaymentLink, Schedule, DateTimes, Rules, SocialLinks, Accessibility y AdditionalImages a JSON para almacenarlos en la base de datos
 	paymentLinkJSON, err := json.Marshal(updatedEvent.PaymentLink)
@@ -255,18 +294,72 @@ func UpdateEventByID(id string, updatedEvent Event) error {
 		return err
 	}
 
-	_, err = database.DB.Exec(query, updatedEvent.Name, updatedEvent.Description, updatedEvent.Location.Address, updatedEvent.Location.Lng, updatedEvent.Location.Lat, dateTimesJSON, updatedEvent.UserID, updatedEvent.UpdatedAt, paymentLinkJSON, pq.Array(updatedEvent.Tags), updatedEvent.TransportGuide, scheduleJSON, updatedEvent.ExclusiveParking, updatedEvent.MinPrice, rulesJSON, socialLinksJSON, accessibilityJSON, updatedEvent.DeliveryMethod, updatedEvent.MainImageURL, additionalImagesJSON, updatedEvent.Category, id)
+	_, err = exec.Exec(query, updatedEvent.Name, updatedEvent.Description, updatedEvent.Location.Address, updatedEvent.Location.Lng, updatedEvent.Location.Lat, dateTimesJSON, updatedEvent.UserID, updatedEvent.UpdatedAt, paymentLinkJSON, pq.Array(updatedEvent.Tags), updatedEvent.TransportGuide, scheduleJSON, updatedEvent.ExclusiveParking, updatedEvent.MinPrice, rulesJSON, socialLinksJSON, accessibilityJSON, updatedEvent.DeliveryMethod, updatedEvent.MainImageURL, additionalImagesJSON, updatedEvent.Category, updatedEvent.Outdoor, updatedEvent.WeatherAlertsAttendees, updatedEvent.Location.City, updatedEvent.Location.Province, updatedEvent.VenueID, id)
 	return err
 }
 
-func DeleteEventByID(id string) error {
-	query := `DELETE FROM events WHERE id = $1`
-	_, err := database.DB.Exec(query, id)
+// DeleteEventByID borra primero las inscripciones y el historial del clima, que referencian al evento
+func DeleteEventByID(exec database.Executor, id string) error {
+	_, err := exec.Exec(`DELETE FROM registrations WHERE event_id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`DELETE FROM weather_checks WHERE event_id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`DELETE FROM events WHERE id = $1`, id)
 	return err
 }
 
+const eventDateLayout = "02/01/2006"
+
+var (
+	eventTimezoneOnce sync.Once
+	eventTimezone     *time.Location
+)
+
+// EventTimezone es la zona horaria en la que se cargan las fechas de los eventos (EVENT_TIMEZONE)
+func EventTimezone() *time.Location {
+	eventTimezoneOnce.Do(func() {
+		name := os.Getenv("EVENT_TIMEZONE")
+		if name == "" {
+			name = "America/Argentina/Buenos_Aires"
+		}
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			// Sin base de zonas horarias en el sistema se usa la hora de Argentina
+			location = time.FixedZone("ART", -3*60*60)
+		}
+		eventTimezone = location
+	})
+	return eventTimezone
+}
+
+// OccurrenceTime devuelve el inicio de una de las fechas del evento (clave de DateTimes, dd/mm/aaaa).
+// Si la hora no tiene el formato HH:MM se toma el comienzo del día.
+func (e Event) OccurrenceTime(date string) (time.Time, bool) {
+	dateTime, exists := e.DateTimes[date]
+	if !exists {
+		return time.Time{}, false
+	}
+
+	day, err := time.ParseInLocation(eventDateLayout, date, EventTimezone())
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	clock, err := time.Parse("15:04", strings.TrimSpace(dateTime.Time))
+	if err != nil {
+		return day, true
+	}
+	return day.Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute), true
+}
+
 func GetAllTags() ([]string, error) {
-	query := `SELECT DISTINCT UNNEST(tags) FROM events`
+	query := `SELECT DISTINCT UNNEST(tags) FROM events WHERE ` + publicEventFilter
 	rows, err := database.DB.Query(query)
 	if err != nil {
 		return nil, err
@@ -291,7 +384,7 @@ func GetAllTags() ([]string, error) {
 }
 
 func GetAllCategories() ([]string, error) {
-	query := `SELECT DISTINCT category FROM events`
+	query := `SELECT DISTINCT category FROM events WHERE ` + publicEventFilter
 	rows, err := database.DB.Query(query)
 	if err != nil {
 		return nil, err
@@ -317,9 +410,9 @@ func GetAllCategories() ([]string, error) {
 
 func GetEventsByTags(tags []string) ([]Event, error) {
 	query := `
-		SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category
+		SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category, outdoor, weather_alerts_attendees, COALESCE(location_city, ''), COALESCE(location_province, ''), COALESCE(venue_id, ''), status, publish_at, COALESCE(status_reason, '')
 		FROM events
-		WHERE tags && $1
+		WHERE tags && $1 AND ` + publicEventFilter + `
 	`
 	rows, err := database.DB.Query(query, pq.Array(tags))
 	if err != nil {
@@ -331,7 +424,7 @@ func GetEventsByTags(tags []string) ([]Event, error) {
 	for rows.Next() {
 		var event Event
 		var paymentLinkJSON, scheduleJSON, dateTimesJSON, rulesJSON, socialLinksJSON, accessibilityJSON, additionalImagesJSON []byte
-		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category)
+		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category, &event.Outdoor, &event.WeatherAlertsAttendees, &event.Location.City, &event.Location.Province, &event.VenueID, &event.Status, &event.PublishAt, &event.StatusReason)
 		if err != nil {
 			return nil, err
 		}
@@ -398,9 +491,9 @@ func GetEventsByTags(tags []string) ([]Event, error) {
 
 func GetEventsByCategory(category string) ([]Event, error) {
 	query := `
-		SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category
+		SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category, outdoor, weather_alerts_attendees, COALESCE(location_city, ''), COALESCE(location_province, ''), COALESCE(venue_id, ''), status, publish_at, COALESCE(status_reason, '')
 		FROM events
-		WHERE category = $1
+		WHERE category = $1 AND ` + publicEventFilter + `
 	`
 	rows, err := database.DB.Query(query, category)
 	if err != nil {
@@ -412,7 +505,7 @@ func GetEventsByCategory(category string) ([]Event, error) {
 	for rows.Next() {
 		var event Event
 		var paymentLinkJSON, scheduleJSON, dateTimesJSON, rulesJSON, socialLinksJSON, accessibilityJSON, additionalImagesJSON []byte
-		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category)
+		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category, &event.Outdoor, &event.WeatherAlertsAttendees, &event.Location.City, &event.Location.Province, &event.VenueID, &event.Status, &event.PublishAt, &event.StatusReason)
 		if err != nil {
 			return nil, err
 		}
@@ -434,9 +527,9 @@ func GetEventsByDate(date string) ([]Event, error) {
 	formattedDate := parsedDate.Format("02/01/2006")
 
 	query := `
-		SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category
+		SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category, outdoor, weather_alerts_attendees, COALESCE(location_city, ''), COALESCE(location_province, ''), COALESCE(venue_id, ''), status, publish_at, COALESCE(status_reason, '')
 		FROM events
-		WHERE date_times ? $1
+		WHERE date_times ? $1 AND ` + publicEventFilter + `
 	`
 	rows, err := database.DB.Query(query, formattedDate)
 	if err != nil {
@@ -448,7 +541,7 @@ func GetEventsByDate(date string) ([]Event, error) {
 	for rows.Next() {
 		var event Event
 		var paymentLinkJSON, scheduleJSON, dateTimesJSON, rulesJSON, socialLinksJSON, accessibilityJSON, additionalImagesJSON []byte
-		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category)
+		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category, &event.Outdoor, &event.WeatherAlertsAttendees, &event.Location.City, &event.Location.Province, &event.VenueID, &event.Status, &event.PublishAt, &event.StatusReason)
 		if err != nil {
 			return nil, err
 		}
@@ -500,9 +593,9 @@ func GetEventsByDate(date string) ([]Event, error) {
 
 func GetEventsByName(name string) ([]Event, error) {
 	query := `
-		SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category
+		SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category, outdoor, weather_alerts_attendees, COALESCE(location_city, ''), COALESCE(location_province, ''), COALESCE(venue_id, ''), status, publish_at, COALESCE(status_reason, '')
 		FROM events
-		WHERE LOWER(name) LIKE LOWER($1 || '%')
+		WHERE LOWER(name) LIKE LOWER($1 || '%') AND ` + publicEventFilter + `
 	`
 	rows, err := database.DB.Query(query, name)
 	if err != nil {
@@ -514,7 +607,74 @@ func GetEventsByName(name string) ([]Event, error) {
 	for rows.Next() {
 		var event Event
 		var paymentLinkJSON, scheduleJSON, dateTimesJSON, rulesJSON, socialLinksJSON, accessibilityJSON, additionalImagesJSON []byte
-		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category)
+		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category, &event.Outdoor, &event.WeatherAlertsAttendees, &event.Location.City, &event.Location.Province, &event.VenueID, &event.Status, &event.PublishAt, &event.StatusReason)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(paymentLinkJSON, &event.PaymentLink)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(scheduleJSON, &event.Schedule)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(dateTimesJSON, &event.DateTimes)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(rulesJSON, &event.Rules)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(socialLinksJSON, &event.SocialLinks)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(accessibilityJSON, &event.Accessibility)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(additionalImagesJSON, &event.AdditionalImages)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// GetEventsByLocation filtra por ciudad y/o provincia sin distinguir mayúsculas; un filtro vacío no se aplica
+func GetEventsByLocation(city, province string) ([]Event, error) {
+	query := `
+		SELECT id, name, description, location_address, location_lng, location_lat, date_times, user_id, created_at, updated_at, payment_link, tags, transport_guide, schedule, exclusive_parking, min_price, rules, social_links, accessibility, delivery_method, main_image_url, additional_images, category, outdoor, weather_alerts_attendees, COALESCE(location_city, ''), COALESCE(location_province, ''), COALESCE(venue_id, ''), status, publish_at, COALESCE(status_reason, '')
+		FROM events
+		WHERE ($1 = '' OR LOWER(location_city) = LOWER($1)) AND ($2 = '' OR LOWER(location_province) = LOWER($2)) AND ` + publicEventFilter + `
+	`
+	rows, err := database.DB.Query(query, city, province)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var event Event
+		var paymentLinkJSON, scheduleJSON, dateTimesJSON, rulesJSON, socialLinksJSON, accessibilityJSON, additionalImagesJSON []byte
+		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location.Address, &event.Location.Lng, &event.Location.Lat, &dateTimesJSON, &event.UserID, &event.CreatedAt, &event.UpdatedAt, &paymentLinkJSON, pq.Array(&event.Tags), &event.TransportGuide, &scheduleJSON, &event.ExclusiveParking, &event.MinPrice, &rulesJSON, &socialLinksJSON, &accessibilityJSON, &event.DeliveryMethod, &event.MainImageURL, &additionalImagesJSON, &event.Category, &event.Outdoor, &event.WeatherAlertsAttendees, &event.Location.City, &event.Location.Province, &event.VenueID, &event.Status, &event.PublishAt, &event.StatusReason)
 		if err != nil {
 			return nil, err
 		}
@@ -568,8 +728,9 @@ func GetEventSummaries(page, limit int) ([]EventSummary, error) {
 	offset := (page - 1) * limit // Calcular el desplazamiento
 
 	query := `
-		SELECT name, main_image_url, date_times, min_price
+		SELECT id, name, main_image_url, date_times, min_price
 		FROM events
+		WHERE ` + publicEventFilter + `
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
 	`
@@ -579,11 +740,31 @@ func GetEventSummaries(page, limit int) ([]EventSummary, error) {
 	}
 	defer rows.Close()
 
-	var summaries []EventSummary
+	return scanEventSummaries(rows)
+}
+
+func GetEventSummariesByUserID(userID string) ([]EventSummary, error) {
+	query := `
+		SELECT id, name, main_image_url, date_times, min_price
+		FROM events
+		WHERE user_id = $1 AND ` + publicEventFilter + `
+		ORDER BY created_at DESC
+	`
+	rows, err := database.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEventSummaries(rows)
+}
+
+func scanEventSummaries(rows *sql.Rows) ([]EventSummary, error) {
+	summaries := []EventSummary{}
 	for rows.Next() {
 		var summary EventSummary
 		var dateTimesJSON []byte
-		err := rows.Scan(&summary.Name, &summary.MainImageURL, &dateTimesJSON, &summary.MinPrice)
+		err := rows.Scan(&summary.ID, &summary.Name, &summary.MainImageURL, &dateTimesJSON, &summary.MinPrice)
 		if err != nil {
 			return nil, err
 		}
@@ -605,7 +786,7 @@ func GetEventSummaries(page, limit int) ([]EventSummary, error) {
 		summaries = append(summaries, summary)
 	}
 
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
@@ -613,6 +794,7 @@ func GetEventSummaries(page, limit int) ([]EventSummary, error) {
 }
 
 type EventSummary struct {
+	ID                 string  `json:"id"`
 	Name               string  `json:"name"`
 	FirstAvailableDate string  `json:"first_available_date"`
 	MainImageURL       string  `json:"main_image_url"`
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+// Estados del ciclo de vida de un evento. Solo los publicados y postergados aparecen en los listados;
+// los borradores los ve únicamente su organizador.
+const (
+	EventStatusDraft     = "draft"
+	EventStatusPublished = "published"
+	EventStatusPostponed = "postponed"
+	EventStatusCancelled = "cancelled"
+	EventStatusArchived  = "archived"
+)
+
+// publicEventFilter es la condición de los listados públicos, para que todas las consultas muestren los mismos estados
+const publicEventFilter = `status IN ('` + EventStatusPublished + `', '` + EventStatusPostponed + `')`
+
+// eventTransitions son los cambios de estado permitidos. Un borrador no se archiva: se borra.
+var eventTransitions = map[string][]string{
+	EventStatusDraft:     {EventStatusPublished},
+	EventStatusPublished: {EventStatusPostponed, EventStatusCancelled, EventStatusArchived},
+	EventStatusPostponed: {EventStatusPublished, EventStatusCancelled, EventStatusArchived},
+	EventStatusCancelled: {EventStatusArchived},
+}
+
+func IsEventStatus(status string) bool {
+	switch status {
+	case EventStatusDraft, EventStatusPublished, EventStatusPostponed, EventStatusCancelled, EventStatusArchived:
+		return true
+	}
+	return false
+}
+
+func CanTransition(from, to string) bool {
+	for _, allowed := range eventTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedTransitions devuelve a qué estados puede pasar el evento
+func (e Event) AllowedTransitions() []string {
+	allowed := eventTransitions[e.Status]
+	if allowed == nil {
+		return []string{}
+	}
+	return allowed
+}
+
+// IsVisible indica si cualquiera puede ver el evento por su ID; los cancelados y archivados se siguen mostrando
+func (e Event) IsVisible() bool {
+	return e.Status != EventStatusDraft
+}
+
+// AcceptsRegistrations: solo se puede inscribir a eventos publicados
+func (e Event) AcceptsRegistrations() bool {
+	return e.Status == EventStatusPublished
+}
+
+// HasUpcomingDates indica si a la última fecha todavía no le llegó el turno. Si las fechas no tienen
+// el formato dd/mm/aaaa no se puede saber y se toman como pendientes.
+func (e Event) HasUpcomingDates() bool {
+	last, ok := e.LastOccurrence()
+	return !ok || last.After(time.Now())
+}
+
+// LastOccurrence es el inicio de la última fecha del evento; false si no tiene fechas válidas
+func (e Event) LastOccurrence() (time.Time, bool) {
+	var last time.Time
+	found := false
+	for date := range e.DateTimes {
+		occurrence, ok := e.OccurrenceTime(date)
+		if ok && (!found || occurrence.After(last)) {
+			last, found = occurrence, true
+		}
+	}
+	return last, found
+}
+
+// SetEventStatus cambia el estado solo si el evento sigue en from, para que dos cambios simultáneos
+// no se pisen. Devuelve false si el estado ya era otro. publishAt solo se conserva en los borradores.
+func SetEventStatus(exec database.Executor, id, from, to, reason string, publishAt *time.Time) (bool, error) {
+	now := time.Now().Format(time.RFC3339)
+	query := `
+		UPDATE events
+		SET status = $1, status_reason = NULLIF($2, ''), publish_at = $3, updated_at = $4,
+			archived_at = CASE WHEN $1 = $7 THEN $4 ELSE NULL END
+		WHERE id = $5 AND status = $6
+	`
+	result, err := exec.Exec(query, to, reason, publishAt, now, id, from, EventStatusArchived)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SchedulePublish programa (o con nil, quita) la publicación de un borrador
+func SchedulePublish(id string, publishAt *time.Time) (bool, error) {
+	query := `UPDATE events SET publish_at = $1, updated_at = $2 WHERE id = $3 AND status = $4`
+	result, err := database.DB.Exec(query, publishAt, time.Now().Format(time.RFC3339), id, EventStatusDraft)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// GetEventIDsDueForPublishing son los borradores cuya publicación programada ya llegó
+func GetEventIDsDueForPublishing(now time.Time) ([]string, error) {
+	return queryEventIDs(`SELECT id FROM events WHERE status = $1 AND publish_at <= $2 ORDER BY publish_at`, EventStatusDraft, now)
+}
+
+// GetEventIDsEndedBy son los eventos publicados o postergados cuyas fechas son todas del día de day o
+// anteriores. Los que tienen alguna fecha sin el formato dd/mm/aaaa no se incluyen.
+func GetEventIDsEndedBy(day time.Time) ([]string, error) {
+	query := `
+		SELECT id FROM events
+		WHERE ` + publicEventFilter + ` AND date_times <> '{}'::jsonb
+			AND NOT EXISTS (
+				SELECT 1 FROM jsonb_object_keys(date_times) AS date_key
+				WHERE CASE WHEN date_key ~ '^\d{2}/\d{2}/\d{4}$' THEN to_date(date_key, 'DD/MM/YYYY') > $1::date ELSE TRUE END
+			)
+	`
+	return queryEventIDs(query, day.Format("2006-01-02"))
+}
+
+func queryEventIDs(query string, args ...interface{}) ([]string, error) {
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetEventSummariesByOrganizer lista todos los eventos del organizador, borradores incluidos.
+// Con status vacío no filtra por estado.
+func GetEventSummariesByOrganizer(userID, status string) ([]OrganizerEventSummary, error) {
+	query := `
+		SELECT id, name, main_image_url, date_times, min_price, status, publish_at
+		FROM events
+		WHERE user_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+	`
+	rows, err := database.DB.Query(query, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []OrganizerEventSummary{}
+	for rows.Next() {
+		var summary OrganizerEventSummary
+		var dateTimesJSON []byte
+		err := rows.Scan(&summary.ID, &summary.Name, &summary.MainImageURL, &dateTimesJSON, &summary.MinPrice, &summary.Status, &summary.PublishAt)
+		if err != nil {
+			return nil, err
+		}
+
+		var dateTimes map[string]DateTime
+		if err := json.Unmarshal(dateTimesJSON, &dateTimes); err != nil {
+			return nil, err
+		}
+		for date, dateTime := range dateTimes {
+			if dateTime.Status == DateStatusAvailable || dateTime.Status == DateStatusFewLeft {
+				summary.FirstAvailableDate = date
+				break
+			}
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, rows.Err()
+}
+
+type OrganizerEventSummary struct {
+	EventSummary
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+}
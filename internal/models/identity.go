@@ -0,0 +1,127 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Tiempo máximo entre el inicio del login con OIDC y la vuelta del proveedor
+const oidcStateTTL = 10 * time.Minute
+
+type OIDCState struct {
+	State        string
+	CodeVerifier string
+	Nonce        string
+}
+
+func SaveOIDCState(state OIDCState) error {
+	// Aprovechar cada login para limpiar los intentos abandonados
+	_, err := database.DB.Exec(`DELETE FROM oidc_states WHERE created_at < $1`, time.Now().Add(-oidcStateTTL))
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO oidc_states (state, code_verifier, nonce, created_at) VALUES ($1, $2, $3, $4)`
+	_, err = database.DB.Exec(query, state.State, state.CodeVerifier, state.Nonce, time.Now())
+	return err
+}
+
+// ConsumeOIDCState borra y devuelve el state; cada state solo se puede usar una vez
+func ConsumeOIDCState(state string) (*OIDCState, error) {
+	query := `DELETE FROM oidc_states WHERE state = $1 AND created_at > $2 RETURNING state, code_verifier, nonce`
+	row := database.DB.QueryRow(query, state, time.Now().Add(-oidcStateTTL))
+
+	var result OIDCState
+	err := row.Scan(&result.State, &result.CodeVerifier, &result.Nonce)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+type UserIdentity struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id"`
+	Issuer    string `json:"issuer"`
+	Subject   string `json:"subject"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"created_at"`
+}
+
+func GetUserIDByIdentity(issuer, subject string) (string, error) {
+	query := `SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2`
+	row := database.DB.QueryRow(query, issuer, subject)
+
+	var userID string
+	err := row.Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// HasLinkedIdentity indica si la cuenta inicia sesión con un proveedor externo
+func HasLinkedIdentity(userID string) (bool, error) {
+	var linked bool
+	err := database.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM user_identities WHERE user_id = $1)`, userID).Scan(&linked)
+	return linked, err
+}
+
+func (i *UserIdentity) Save() error {
+	i.CreatedAt = time.Now().Format(time.RFC3339)
+
+	query := `INSERT INTO user_identities (id, user_id, issuer, subject, email, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := database.DB.Exec(query, i.ID, i.UserID, i.Issuer, i.Subject, i.Email, i.CreatedAt)
+	return err
+}
+
+// CreateUserWithIdentity crea una cuenta para un usuario externo y la vincula en una transacción.
+// La contraseña es aleatoria: el usuario puede definir una con el flujo de recuperación.
+func CreateUserWithIdentity(username string, identity *UserIdentity) error {
+	randomPassword := make([]byte, 32)
+	if _, err := rand.Read(randomPassword); err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(randomPassword)), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Format(time.RFC3339)
+	identity.UserID = uuid.New().String()
+	identity.CreatedAt = now
+
+	query := `INSERT INTO users (id, username, email, password, whatsapp, created_at, updated_at) VALUES ($1, $2, $3, $4, NULL, $5, $5)`
+	_, err = tx.Exec(query, identity.UserID, username, identity.Email, string(hashedPassword), now)
+	if err != nil {
+		return err
+	}
+
+	query = `INSERT INTO user_identities (id, user_id, issuer, subject, email, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err = tx.Exec(query, identity.ID, identity.UserID, identity.Issuer, identity.Subject, identity.Email, identity.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
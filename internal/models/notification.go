@@ -0,0 +1,137 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+// UserNotification es un aviso de la bandeja dentro de la aplicación
+type UserNotification struct {
+	ID        string          `json:"id"`
+	UserID    string          `json:"-"`
+	Type      string          `json:"type,omitempty"`
+	Template  string          `json:"template"`
+	Title     string          `json:"title"`
+	Data      json.RawMessage `json:"data"`
+	ReadAt    *time.Time      `json:"read_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+const notificationColumns = `id, user_id, COALESCE(type, ''), template, title, data, read_at, created_at`
+
+// CreateNotification usa un ID fijo (el del trabajo que la envía) para no duplicarla si el trabajo se reintenta.
+// Devuelve false si ya existía.
+func CreateNotification(n *UserNotification) (bool, error) {
+	query := `
+		INSERT INTO notifications (id, user_id, type, template, title, data, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7)
+		ON CONFLICT (id) DO NOTHING
+	`
+	result, err := database.DB.Exec(query, n.ID, n.UserID, n.Type, n.Template, n.Title, []byte(n.Data), n.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func GetNotifications(userID string, unreadOnly bool, page, limit int) ([]UserNotification, int, error) {
+	filter := ""
+	if unreadOnly {
+		filter = " AND read_at IS NULL"
+	}
+
+	var total int
+	err := database.DB.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = $1`+filter, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE user_id = $1` + filter + ` ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3`
+	notifications, err := queryNotifications(query, userID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return notifications, total, nil
+}
+
+// GetNotificationsAfter devuelve, en orden, las creadas después de la indicada; sirve para que un
+// stream que se reconecta con Last-Event-ID recupere lo que se perdió. Se compara (created_at, id),
+// el mismo orden del listado, para no saltear las que tienen el mismo created_at.
+func GetNotificationsAfter(userID, notificationID string, limit int) ([]UserNotification, error) {
+	query := `
+		SELECT ` + notificationColumns + ` FROM notifications
+		WHERE user_id = $1 AND (created_at, id) > (SELECT created_at, id FROM notifications WHERE id = $2 AND user_id = $1)
+		ORDER BY created_at, id
+		LIMIT $3
+	`
+	return queryNotifications(query, userID, notificationID, limit)
+}
+
+func queryNotifications(query string, args ...interface{}) ([]UserNotification, error) {
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []UserNotification{}
+	for rows.Next() {
+		var n UserNotification
+		var data []byte
+		var readAt sql.NullTime
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.Template, &n.Title, &data, &readAt, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		n.Data = data
+		if readAt.Valid {
+			n.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func CountUnreadNotifications(userID string) (int, error) {
+	var count int
+	err := database.DB.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationRead devuelve false si la notificación no existe o es de otro usuario
+func MarkNotificationRead(userID, notificationID string) (bool, error) {
+	query := `UPDATE notifications SET read_at = COALESCE(read_at, $1) WHERE id = $2 AND user_id = $3`
+	result, err := database.DB.Exec(query, time.Now(), notificationID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func MarkAllNotificationsRead(userID string) (int64, error) {
+	result, err := database.DB.Exec(`UPDATE notifications SET read_at = $1 WHERE user_id = $2 AND read_at IS NULL`, time.Now(), userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+type NotificationPreference struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	Enabled bool   `json:"enabled"`
+}
+
+// GetNotificationPreferences devuelve solo las preferencias guardadas, indexadas por tipo y canal
+func GetNotificationPreferences(exec database.Executor, userID string) (map[string]map[string]bool, error) {
+	rows, err := exec.Query(`SELECT type, channel, enabled FROM notification_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	preferences := map[string]map[string]bool{}
+	for rows.Next() {
+		var p NotificationPreference
+		if err := rows.Scan(&p.Type, &p.Channel, &p.Enabled); err != nil {
+			return nil, err
+		}
+		if preferences[p.Type] == nil {
+			preferences[p.Type] = map[string]bool{}
+		}
+		preferences[p.Type][p.Channel] = p.Enabled
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+func SetNotificationPreferences(userID string, preferences []NotificationPreference) error {
+	return database.WithTx(func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO notification_preferences (user_id, type, channel, enabled, updated_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (user_id, type, channel) DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = EXCLUDED.updated_at
+		`
+		now := time.Now()
+		for _, p := range preferences {
+			if _, err := tx.Exec(query, userID, p.Type, p.Channel, p.Enabled, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/lib/pq"
+)
+
+// OrganizerProfile es la página pública de quien organiza eventos. Si el usuario no la completó,
+// el nombre y el logo salen de su perfil de usuario.
+type OrganizerProfile struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Bio           string            `json:"bio"`
+	LogoURL       string            `json:"logo_url"`
+	SocialLinks   map[string]string `json:"social_links"`
+	Verified      bool              `json:"verified"`
+	FollowerCount int               `json:"follower_count"`
+}
+
+// OrganizerProfileInput son los campos que edita el propio organizador
+type OrganizerProfileInput struct {
+	Name        string            `json:"name"`
+	Bio         string            `json:"bio"`
+	LogoURL     string            `json:"logo_url"`
+	SocialLinks map[string]string `json:"social_links"`
+}
+
+const organizerProfileColumns = `
+	users.id,
+	COALESCE(NULLIF(organizer_profiles.name, ''), NULLIF(users.display_name, ''), users.username),
+	COALESCE(organizer_profiles.bio, ''),
+	COALESCE(NULLIF(organizer_profiles.logo_url, ''), users.avatar_url, ''),
+	organizer_profiles.social_links,
+	COALESCE(organizer_profiles.verified, FALSE),
+	(SELECT COUNT(*) FROM follows WHERE follows.organizer_id = users.id)`
+
+func scanOrganizerProfile(row rowScanner) (*OrganizerProfile, error) {
+	var profile OrganizerProfile
+	var socialLinksJSON []byte
+	err := row.Scan(&profile.ID, &profile.Name, &profile.Bio, &profile.LogoURL, &socialLinksJSON, &profile.Verified, &profile.FollowerCount)
+	if err != nil {
+		return nil, err
+	}
+
+	profile.SocialLinks = map[string]string{}
+	if socialLinksJSON != nil {
+		if err := json.Unmarshal(socialLinksJSON, &profile.SocialLinks); err != nil {
+			return nil, err
+		}
+	}
+	return &profile, nil
+}
+
+// GetOrganizerProfile devuelve nil si el usuario no es organizador: no cargó su perfil de organizador
+// ni tiene eventos publicados, así no se exponen ni se pueden seguir cuentas de asistentes
+func GetOrganizerProfile(userID string) (*OrganizerProfile, error) {
+	query := `
+		SELECT ` + organizerProfileColumns + `
+		FROM users LEFT JOIN organizer_profiles ON organizer_profiles.user_id = users.id
+		WHERE users.id = $1
+			AND (organizer_profiles.user_id IS NOT NULL OR EXISTS (SELECT 1 FROM events WHERE events.user_id = users.id AND events.status = $2))
+	`
+	profile, err := scanOrganizerProfile(database.DB.QueryRow(query, userID, EventStatusPublished))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return profile, err
+}
+
+// SaveOrganizerProfile crea o reemplaza el perfil. La verificación corresponde al nombre revisado:
+// si el nombre cambia, la insignia se pierde hasta que un administrador la vuelva a dar.
+func SaveOrganizerProfile(userID string, input OrganizerProfileInput) error {
+	socialLinksJSON, err := json.Marshal(input.SocialLinks)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO organizer_profiles (user_id, name, bio, logo_url, social_links, created_at, updated_at)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), $5, $6, $6)
+		ON CONFLICT (user_id) DO UPDATE
+		SET name = EXCLUDED.name, bio = EXCLUDED.bio, logo_url = EXCLUDED.logo_url, social_links = EXCLUDED.social_links, updated_at = EXCLUDED.updated_at,
+			verified = organizer_profiles.verified AND organizer_profiles.name IS NOT DISTINCT FROM EXCLUDED.name,
+			verified_at = CASE WHEN organizer_profiles.name IS NOT DISTINCT FROM EXCLUDED.name THEN organizer_profiles.verified_at END
+	`
+	_, err = database.DB.Exec(query, userID, input.Name, input.Bio, input.LogoURL, socialLinksJSON, time.Now())
+	return err
+}
+
+// SetOrganizerVerified crea el perfil si todavía no existe
+func SetOrganizerVerified(userID string, verified bool) error {
+	now := time.Now()
+	var verifiedAt interface{}
+	if verified {
+		verifiedAt = now
+	}
+
+	query := `
+		INSERT INTO organizer_profiles (user_id, verified, verified_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET verified = EXCLUDED.verified, verified_at = EXCLUDED.verified_at, updated_at = EXCLUDED.updated_at
+	`
+	_, err := database.DB.Exec(query, userID, verified, verifiedAt, now)
+	return err
+}
+
+// Follow no falla si ya lo seguía
+func Follow(followerID, organizerID string) error {
+	query := `INSERT INTO follows (follower_id, organizer_id, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+	_, err := database.DB.Exec(query, followerID, organizerID, time.Now())
+	return err
+}
+
+func Unfollow(followerID, organizerID string) error {
+	_, err := database.DB.Exec(`DELETE FROM follows WHERE follower_id = $1 AND organizer_id = $2`, followerID, organizerID)
+	return err
+}
+
+func IsFollowing(followerID, organizerID string) (bool, error) {
+	var following bool
+	err := database.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM follows WHERE follower_id = $1 AND organizer_id = $2)`, followerID, organizerID).Scan(&following)
+	return following, err
+}
+
+// GetFollowedOrganizers devuelve los organizadores que sigue el usuario, del último al primero
+func GetFollowedOrganizers(followerID string) ([]OrganizerProfile, error) {
+	query := `
+		SELECT ` + organizerProfileColumns + `
+		FROM follows
+		JOIN users ON users.id = follows.organizer_id
+		LEFT JOIN organizer_profiles ON organizer_profiles.user_id = users.id
+		WHERE follows.follower_id = $1
+		ORDER BY follows.created_at DESC
+	`
+	rows, err := database.DB.Query(query, followerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	organizers := []OrganizerProfile{}
+	for rows.Next() {
+		profile, err := scanOrganizerProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		organizers = append(organizers, *profile)
+	}
+
+	return organizers, rows.Err()
+}
+
+// GetFollowerRecipients son los seguidores a los que avisar de un evento nuevo
+func GetFollowerRecipients(exec database.Executor, organizerID string) ([]Recipient, error) {
+	query := `SELECT ` + recipientColumns + ` FROM follows JOIN users ON users.id = follows.follower_id WHERE follows.organizer_id = $1`
+	rows, err := exec.Query(query, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []Recipient
+	for rows.Next() {
+		var r Recipient
+		if err := rows.Scan(&r.UserID, &r.Email, &r.Whatsapp, &r.Locale, pq.Array(&r.Channels)); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+
+	return recipients, rows.Err()
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 
 	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/lib/pq"
 )
 
 type Registration struct {
@@ -16,16 +17,60 @@ type Registration struct {
 	PaymentLink string `json:"payment_link"`
 }
 
-func (r *Registration) Save() error {
-	query := `INSERT INTO registrations (id, event_id, user_id, whatsapp, created_at, event_date, payment_link) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	stmt, err := database.DB.Prepare(query)
+func (r *Registration) Save(exec database.Executor) error {
+	query := `INSERT INTO registrations (id, event_id, user_id, whatsapp, created_at, event_date, payment_link) VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7)`
+	_, err := exec.Exec(query, r.ID, r.EventID, r.UserID, r.Whatsapp, r.CreatedAt, r.EventDate, r.PaymentLink)
+	return err
+}
+
+func GetRegistrationByID(id string) (*Registration, error) {
+	query := `SELECT id, event_id, COALESCE(user_id, ''), COALESCE(whatsapp, ''), created_at, COALESCE(event_date, ''), COALESCE(payment_link, '') FROM registrations WHERE id = $1`
+	row := database.DB.QueryRow(query, id)
+
+	var r Registration
+	err := row.Scan(&r.ID, &r.EventID, &r.UserID, &r.Whatsapp, &r.CreatedAt, &r.EventDate, &r.PaymentLink)
 	if err != nil {
-		return err
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(r.ID, r.EventID, r.UserID, r.Whatsapp, r.CreatedAt, r.EventDate, r.PaymentLink)
-	return err
+	return &r, nil
+}
+
+// Registrant es un inscripto con los datos necesarios para avisarle de cambios en el evento
+type Registrant struct {
+	Recipient
+	RegistrationID string
+	EventDate      string
+}
+
+// GetRegistrants ignora las inscripciones anonimizadas de cuentas borradas
+func GetRegistrants(exec database.Executor, eventID string) ([]Registrant, error) {
+	query := `
+		SELECT registrations.id, COALESCE(registrations.event_date, ''), ` + recipientColumns + `
+		FROM registrations
+		JOIN users ON registrations.user_id = users.id
+		WHERE registrations.event_id = $1
+	`
+	rows, err := exec.Query(query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var registrants []Registrant
+	for rows.Next() {
+		var r Registrant
+		err := rows.Scan(&r.RegistrationID, &r.EventDate, &r.UserID, &r.Email, &r.Whatsapp, &r.Locale, pq.Array(&r.Channels))
+		if err != nil {
+			return nil, err
+		}
+		registrants = append(registrants, r)
+	}
+
+	return registrants, rows.Err()
 }
 
 func IsUserRegisteredForEvent(eventID, userID string) (bool, error) {
@@ -41,23 +86,36 @@ func IsUserRegisteredForEvent(eventID, userID string) (bool, error) {
 	return count > 0, nil
 }
 
-func DeleteRegistration(eventID, userID string) error {
-	query := `DELETE FROM registrations WHERE event_id = $1 AND user_id = $2`
-	_, err := database.DB.Exec(query, eventID, userID)
-	return err
+// DeleteRegistration devuelve la inscripción borrada, o nil si el usuario no estaba inscripto
+func DeleteRegistration(exec database.Executor, eventID, userID string) (*Registration, error) {
+	query := `DELETE FROM registrations WHERE event_id = $1 AND user_id = $2
+		RETURNING id, event_id, user_id, COALESCE(whatsapp, ''), created_at, COALESCE(event_date, ''), COALESCE(payment_link, '')`
+	var r Registration
+	err := exec.QueryRow(query, eventID, userID).Scan(&r.ID, &r.EventID, &r.UserID, &r.Whatsapp, &r.CreatedAt, &r.EventDate, &r.PaymentLink)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &r, nil
 }
 
 type RegistrationDetail struct {
 	UserID    string `json:"user_id"`
 	Username  string `json:"username"`
-	Email     string `json:"email"`
-	Whatsapp  string `json:"whatsapp"`
+	Email     string `json:"email,omitempty"`
+	Whatsapp  string `json:"whatsapp,omitempty"`
 	CreatedAt string `json:"created_at"`
 }
 
+// GetRegistrationsByEventID es la vista del organizador: respeta la privacidad de cada usuario
 func GetRegistrationsByEventID(eventID string) ([]RegistrationDetail, error) {
 	query := `
-		SELECT users.id, users.username, users.email, users.whatsapp, registrations.created_at
+		SELECT users.id, users.username,
+			CASE WHEN COALESCE(users.share_email_with_organizers, TRUE) THEN users.email ELSE '' END,
+			CASE WHEN COALESCE(users.share_whatsapp_with_organizers, TRUE) THEN COALESCE(users.whatsapp, '') ELSE '' END,
+			registrations.created_at
 		FROM registrations
 		JOIN users ON registrations.user_id = users.id
 		WHERE registrations.event_id = $1
@@ -87,7 +145,7 @@ func GetRegistrationsByEventID(eventID string) ([]RegistrationDetail, error) {
 
 func GetRegistrationByUserID(eventID, userID string) (*RegistrationDetail, error) {
 	query := `
-		SELECT users.id, users.username, users.email, users.whatsapp, registrations.created_at
+		SELECT users.id, users.username, users.email, COALESCE(users.whatsapp, ''), registrations.created_at
 		FROM registrations
 		JOIN users ON registrations.user_id = users.id
 		WHERE registrations.event_id = $1 AND registrations.user_id = $2
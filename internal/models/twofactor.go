@@ -0,0 +1,182 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type TwoFactorState struct {
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+func GetTwoFactorState(userID string) (*TwoFactorState, error) {
+	query := `SELECT COALESCE(totp_secret, ''), COALESCE(totp_enabled, FALSE), COALESCE(totp_last_step, 0) FROM users WHERE id = $1`
+	row := database.DB.QueryRow(query, userID)
+
+	var state TwoFactorState
+	err := row.Scan(&state.Secret, &state.Enabled, &state.LastStep)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func SetPendingTOTPSecret(userID, secret string) error {
+	// Solo se puede reemplazar el secreto mientras el 2FA no esté confirmado
+	query := `UPDATE users SET totp_secret = $1, totp_last_step = 0 WHERE id = $2 AND COALESCE(totp_enabled, FALSE) = FALSE`
+	_, err := database.DB.Exec(query, secret, userID)
+	return err
+}
+
+func EnableTOTP(userID string, step int64, recoveryCodes []string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE users SET totp_enabled = TRUE, totp_last_step = $1 WHERE id = $2`, step, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func DisableTOTP(userID string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE users SET totp_enabled = FALSE, totp_secret = NULL, totp_last_step = 0 WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MarkTOTPStepUsed registra el último paso aceptado; devuelve false si el código ya fue usado
+func MarkTOTPStepUsed(userID string, step int64) (bool, error) {
+	query := `UPDATE users SET totp_last_step = $1 WHERE id = $2 AND COALESCE(totp_last_step, 0) < $1`
+	result, err := database.DB.Exec(query, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID string, codes []string) error {
+	_, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	createdAt := time.Now().Format(time.RFC3339)
+	for _, code := range codes {
+		hashedCode, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+
+		query := `INSERT INTO recovery_codes (id, user_id, code_hash, created_at) VALUES ($1, $2, $3, $4)`
+		_, err = tx.Exec(query, uuid.New().String(), userID, string(hashedCode), createdAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type recoveryCode struct {
+	ID       string
+	CodeHash string
+	Used     bool
+}
+
+// matchRecoveryCode devuelve el ID del código sin usar que coincide, o vacío si no hay ninguno
+func matchRecoveryCode(codes []recoveryCode, code string) string {
+	for _, candidate := range codes {
+		if !candidate.Used && bcrypt.CompareHashAndPassword([]byte(candidate.CodeHash), []byte(code)) == nil {
+			return candidate.ID
+		}
+	}
+	return ""
+}
+
+// UseRecoveryCode consume un código de recuperación si coincide con alguno no usado
+func UseRecoveryCode(userID, code string) (bool, error) {
+	query := `SELECT id, code_hash, used_at IS NOT NULL FROM recovery_codes WHERE user_id = $1`
+	rows, err := database.DB.Query(query, userID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var codes []recoveryCode
+	for rows.Next() {
+		var stored recoveryCode
+		if err := rows.Scan(&stored.ID, &stored.CodeHash, &stored.Used); err != nil {
+			return false, err
+		}
+		codes = append(codes, stored)
+	}
+
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+
+	matchedID := matchRecoveryCode(codes, code)
+	if matchedID == "" {
+		return false, nil
+	}
+
+	result, err := database.DB.Exec(`UPDATE recovery_codes SET used_at = $1 WHERE id = $2 AND used_at IS NULL`, time.Now().Format(time.RFC3339), matchedID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func CountRemainingRecoveryCodes(userID string) (int, error) {
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	row := database.DB.QueryRow(query, userID)
+
+	var count int
+	err := row.Scan(&count)
+	return count, err
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hashRecoveryCode(t *testing.T, code string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+	return string(hash)
+}
+
+func TestMatchRecoveryCodeIsSingleUse(t *testing.T) {
+	codes := []recoveryCode{
+		{ID: "first", CodeHash: hashRecoveryCode(t, "abcde-fghjk")},
+		{ID: "second", CodeHash: hashRecoveryCode(t, "mnpqr-stuvw")},
+	}
+
+	if id := matchRecoveryCode(codes, "mnpqr-stuvw"); id != "second" {
+		t.Fatalf("match = %q, want second", id)
+	}
+
+	// Una vez usado (used_at cargado) el mismo código ya no coincide
+	codes[1].Used = true
+	if id := matchRecoveryCode(codes, "mnpqr-stuvw"); id != "" {
+		t.Errorf("used code matched %q", id)
+	}
+	if id := matchRecoveryCode(codes, "abcde-fghjk"); id != "first" {
+		t.Errorf("match = %q, want first", id)
+	}
+	if id := matchRecoveryCode(codes, "xxxxx-xxxxx"); id != "" {
+		t.Errorf("unknown code matched %q", id)
+	}
+}
@@ -1,13 +1,19 @@
 package models
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
 	_ "github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,15 +23,58 @@ type User struct {
 	Email     string `json:"email" validate:"required,email"`
 	Password  string `json:"password" validate:"required"`
 	Whatsapp  string `json:"whatsapp" validate:"required"`
+	Locale    string `json:"locale"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
+
+	TOTPEnabled bool `json:"-"`
 }
 
 type UserResponse struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Whatsapp string `json:"whatsapp"`
+	ID               string `json:"id"`
+	Username         string `json:"username"`
+	DisplayName      string `json:"display_name"`
+	AvatarURL        string `json:"avatar_url"`
+	Email            string `json:"email"`
+	Whatsapp         string `json:"whatsapp"`
+	Role             string `json:"role"`
+	TwoFactorEnabled bool   `json:"two_factor_enabled"`
+	Locale           string `json:"locale"`
+	// Canales por los que recibe recordatorios y avisos de eventos
+	NotificationChannels []string        `json:"notification_channels"`
+	Privacy              PrivacySettings `json:"privacy"`
+	CreatedAt            string          `json:"created_at"`
+	UpdatedAt            string          `json:"updated_at"`
+}
+
+// PrivacySettings controla qué datos de contacto ven los organizadores en las inscripciones
+type PrivacySettings struct {
+	ShareEmailWithOrganizers    bool `json:"share_email_with_organizers"`
+	ShareWhatsappWithOrganizers bool `json:"share_whatsapp_with_organizers"`
+}
+
+// PublicProfile es lo único que se expone de un usuario en las rutas públicas
+type PublicProfile struct {
+	ID              string         `json:"id"`
+	DisplayName     string         `json:"display_name"`
+	AvatarURL       string         `json:"avatar_url"`
+	OrganizedEvents []EventSummary `json:"organized_events"`
+}
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+const userResponseColumns = `id, username, COALESCE(display_name, ''), COALESCE(avatar_url, ''), email, COALESCE(whatsapp, ''), COALESCE(role, 'user'), COALESCE(totp_enabled, FALSE), COALESCE(locale, 'es'), COALESCE(notification_channels, '{email}'), COALESCE(share_email_with_organizers, TRUE), COALESCE(share_whatsapp_with_organizers, TRUE), created_at, updated_at`
+
+func scanUserResponse(row rowScanner) (*UserResponse, error) {
+	var user UserResponse
+	err := row.Scan(&user.ID, &user.Username, &user.DisplayName, &user.AvatarURL, &user.Email, &user.Whatsapp, &user.Role, &user.TwoFactorEnabled, &user.Locale, pq.Array(&user.NotificationChannels), &user.Privacy.ShareEmailWithOrganizers, &user.Privacy.ShareWhatsappWithOrganizers, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
 func (u *User) Save() error {
@@ -40,19 +89,18 @@ func (u *User) Save() error {
 	u.UpdatedAt = u.CreatedAt
 
 	query := `
-		INSERT INTO users (id, username, email, password, whatsapp, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO users (id, username, email, password, whatsapp, locale, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
 	`
-	_, err = database.DB.Exec(query, u.ID, u.Username, u.Email, u.Password, u.Whatsapp, u.CreatedAt, u.UpdatedAt)
+	_, err = database.DB.Exec(query, u.ID, u.Username, u.Email, u.Password, u.Whatsapp, u.Locale, u.CreatedAt, u.UpdatedAt)
 	return err
 }
 
 func GetUserByID(id string) (*UserResponse, error) {
-	query := `SELECT id, username, email, whatsapp FROM users WHERE id = $1`
+	query := `SELECT ` + userResponseColumns + ` FROM users WHERE id = $1`
 	row := database.DB.QueryRow(query, id)
 
-	var user UserResponse
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Whatsapp)
+	user, err := scanUserResponse(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -60,44 +108,159 @@ func GetUserByID(id string) (*UserResponse, error) {
 		return nil, err
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-func GetAllUsers() ([]UserResponse, error) {
-	query := `SELECT id, username, email, whatsapp FROM users`
-	rows, err := database.DB.Query(query)
+// SearchUsers busca por usuario, nombre visible o email, paginado; solo para administradores
+func SearchUsers(search string, page, limit int) ([]UserResponse, int, error) {
+	offset := (page - 1) * limit
+	filter := `WHERE $1 = '' OR username ILIKE '%' || $1 || '%' OR display_name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%'`
+
+	var total int
+	err := database.DB.QueryRow(`SELECT COUNT(*) FROM users `+filter, search).Scan(&total)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + userResponseColumns + ` FROM users ` + filter + ` ORDER BY created_at DESC LIMIT $2 OFFSET $3`
+	rows, err := database.DB.Query(query, search, limit, offset)
+	if err != nil {
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var users []UserResponse
+	users := []UserResponse{}
 	for rows.Next() {
-		var user UserResponse
-		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Whatsapp)
+		user, err := scanUserResponse(rows)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
-		users = append(users, user)
+		users = append(users, *user)
 	}
 
 	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+func GetPublicProfile(id string) (*PublicProfile, error) {
+	query := `SELECT id, COALESCE(NULLIF(display_name, ''), username), COALESCE(avatar_url, '') FROM users WHERE id = $1`
+	row := database.DB.QueryRow(query, id)
+
+	var profile PublicProfile
+	err := row.Scan(&profile.ID, &profile.DisplayName, &profile.AvatarURL)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	profile.OrganizedEvents, err = GetEventSummariesByUserID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+func GetUserRole(id string) (string, error) {
+	query := `SELECT COALESCE(role, 'user') FROM users WHERE id = $1`
+	row := database.DB.QueryRow(query, id)
+
+	var role string
+	err := row.Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return role, nil
+}
+
+// PromoteAdmins da el rol de administrador a los emails configurados en ADMIN_EMAILS
+func PromoteAdmins(emails []string) error {
+	var cleaned []string
+	for _, email := range emails {
+		if email = strings.TrimSpace(email); email != "" {
+			cleaned = append(cleaned, email)
+		}
+	}
+	if len(cleaned) == 0 {
+		return nil
+	}
+
+	query := `UPDATE users SET role = $1 WHERE email = ANY($2)`
+	_, err := database.DB.Exec(query, RoleAdmin, pq.Array(cleaned))
+	return err
+}
+
+func UpdatePrivacySettings(id string, settings PrivacySettings) error {
+	query := `UPDATE users SET share_email_with_organizers = $1, share_whatsapp_with_organizers = $2, updated_at = $3 WHERE id = $4`
+	_, err := database.DB.Exec(query, settings.ShareEmailWithOrganizers, settings.ShareWhatsappWithOrganizers, time.Now().Format(time.RFC3339), id)
+	return err
+}
+
+func UpdateNotificationChannels(id string, channels []string) error {
+	query := `UPDATE users SET notification_channels = $1, updated_at = $2 WHERE id = $3`
+	_, err := database.DB.Exec(query, pq.Array(channels), time.Now().Format(time.RFC3339), id)
+	return err
+}
+
+// Recipient reúne los datos de contacto y preferencias para enviar un aviso a un usuario
+type Recipient struct {
+	UserID   string
+	Email    string
+	Whatsapp string
+	Locale   string
+	Channels []string
+}
+
+const recipientColumns = `users.id, users.email, COALESCE(users.whatsapp, ''), COALESCE(users.locale, ''), COALESCE(users.notification_channels, '{email}')`
+
+func GetRecipient(exec database.Executor, userID string) (*Recipient, error) {
+	row := exec.QueryRow(`SELECT `+recipientColumns+` FROM users WHERE id = $1`, userID)
+
+	var r Recipient
+	err := row.Scan(&r.UserID, &r.Email, &r.Whatsapp, &r.Locale, pq.Array(&r.Channels))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
 		return nil, err
 	}
 
-	return users, nil
+	return &r, nil
 }
 
 func VerifyPassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
+// GetUserLocale devuelve el idioma de los emails del usuario; "" si no existe
+func GetUserLocale(email string) (string, error) {
+	query := `SELECT COALESCE(locale, '') FROM users WHERE email = $1`
+	row := database.DB.QueryRow(query, email)
+
+	var locale string
+	err := row.Scan(&locale)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	return locale, nil
+}
+
 func GetUserByEmail(email string) (*User, error) {
-	query := `SELECT id, username, email, password FROM users WHERE email = $1`
+	query := `SELECT id, username, email, password, COALESCE(totp_enabled, FALSE) FROM users WHERE email = $1`
 	row := database.DB.QueryRow(query, email)
 
 	var user User
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password)
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.TOTPEnabled)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -108,39 +271,96 @@ func GetUserByEmail(email string) (*User, error) {
 	return &user, nil
 }
 
-func UpdateUserByID(id string, updatedUser User) error {
-	// Obtener el usuario actual para preservar CreatedAt
-	existingUser, err := GetUserByID(id)
-	if err != nil || existingUser == nil {
-		return fmt.Errorf("user not found")
+func GetUserPasswordHash(id string) (string, error) {
+	query := `SELECT password FROM users WHERE id = $1`
+	row := database.DB.QueryRow(query, id)
+
+	var hashedPassword string
+	err := row.Scan(&hashedPassword)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(updatedUser.Password), bcrypt.DefaultCost)
+	return hashedPassword, nil
+}
+
+// UserPatch solo contiene los campos que se pueden editar directamente; nil significa "sin cambios".
+// El email y la contraseña tienen sus propios flujos.
+type UserPatch struct {
+	Username    *string `json:"username"`
+	DisplayName *string `json:"display_name"`
+	AvatarURL   *string `json:"avatar_url"`
+	Whatsapp    *string `json:"whatsapp"`
+	Locale      *string `json:"locale"`
+}
+
+func (p UserPatch) IsEmpty() bool {
+	return p.Username == nil && p.DisplayName == nil && p.AvatarURL == nil && p.Whatsapp == nil && p.Locale == nil
+}
+
+func PatchUser(id string, patch UserPatch) error {
+	var assignments []string
+	var args []interface{}
+
+	set := func(column string, value *string) {
+		if value == nil {
+			return
+		}
+		args = append(args, *value)
+		assignments = append(assignments, fmt.Sprintf("%s = NULLIF($%d, '')", column, len(args)))
+	}
+	set("display_name", patch.DisplayName)
+	set("avatar_url", patch.AvatarURL)
+	set("whatsapp", patch.Whatsapp)
+	if patch.Username != nil {
+		args = append(args, *patch.Username)
+		assignments = append(assignments, fmt.Sprintf("username = $%d", len(args)))
+	}
+	if patch.Locale != nil {
+		args = append(args, *patch.Locale)
+		assignments = append(assignments, fmt.Sprintf("locale = $%d", len(args)))
+	}
+
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	// Establecer la fecha de actualización
+	args = append(args, time.Now().Format(time.RFC3339), id)
+	query := fmt.Sprintf(`UPDATE users SET %s, updated_at = $%d WHERE id = $%d`, strings.Join(assignments, ", "), len(args)-1, len(args))
+
+	result, err := database.DB.Exec(query, args...)
 	if err != nil {
 		return err
 	}
-	updatedUser.Password = string(hashedPassword)
 
-	// Establecer la fecha de actualización
-	updatedUser.UpdatedAt = time.Now().Format(time.RFC3339)
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("user not found")
+	}
 
-	query := `UPDATE users SET username = $1, email = $2, password = $3, whatsapp = $4, updated_at = $5 WHERE id = $6`
-	_, err = database.DB.Exec(query, updatedUser.Username, updatedUser.Email, updatedUser.Password, updatedUser.Whatsapp, updatedUser.UpdatedAt, id)
-	return err
+	return nil
 }
 
-func DeleteUserByID(id string) error {
-	query := `DELETE FROM users WHERE id = $1`
-	_, err := database.DB.Exec(query, id)
-	return err
+// IsUniqueViolation indica si el error es por un email o WhatsApp ya registrado
+func IsUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
 }
 
-func SetResetToken(email string) (string, error) {
+// SetEmailChangeToken guarda el nuevo email pendiente de confirmación junto al hash del token
+func SetEmailChangeToken(exec database.Executor, userID, newEmail string) (string, error) {
 	token := uuid.New().String()
-	expiry := time.Now().Add(1 * time.Hour)
+	expiry := time.Now().Add(24 * time.Hour)
 
-	query := `UPDATE users SET reset_token = $1, reset_token_expiry = $2 WHERE email = $3`
-	_, err := database.DB.Exec(query, token, expiry, email)
+	query := `UPDATE users SET pending_email = $1, email_change_token = $2, email_change_expiry = $3 WHERE id = $4`
+	_, err := exec.Exec(query, newEmail, hashToken(token), expiry, userID)
 	if err != nil {
 		return "", err
 	}
@@ -148,12 +368,102 @@ func SetResetToken(email string) (string, error) {
 	return token, nil
 }
 
+// ConfirmEmailChange aplica el email pendiente si el token es válido y devuelve el email anterior.
+// Debe llamarse dentro de una transacción: la fila queda bloqueada hasta confirmarla.
+func ConfirmEmailChange(tx database.Executor, token string) (userID, oldEmail, newEmail string, err error) {
+	query := `SELECT id, email, pending_email FROM users WHERE email_change_token = $1 AND email_change_expiry > $2 FOR UPDATE`
+	err = tx.QueryRow(query, hashToken(token), time.Now()).Scan(&userID, &oldEmail, &newEmail)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", "", nil
+		}
+		return "", "", "", err
+	}
+
+	query = `UPDATE users SET email = pending_email, pending_email = NULL, email_change_token = NULL, email_change_expiry = NULL, updated_at = $1 WHERE id = $2`
+	_, err = tx.Exec(query, time.Now().Format(time.RFC3339), userID)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return userID, oldEmail, newEmail, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+const resetTokenTTL = time.Hour
+
+// SetResetToken genera un token "selector.verificador": el selector localiza la fila y del
+// verificador solo se guarda el hash. Devuelve "" si no hay ninguna cuenta con ese email.
+func SetResetToken(exec database.Executor, email string) (string, error) {
+	selector, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+
+	query := `UPDATE users SET reset_token = $1, reset_token_hash = $2, reset_token_expiry = $3 WHERE email = $4`
+	result, err := exec.Exec(query, selector, hashToken(verifier), time.Now().Add(resetTokenTTL), email)
+	if err != nil {
+		return "", err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return "", err
+	}
+
+	return selector + "." + verifier, nil
+}
+
 func VerifyResetToken(token string) (string, error) {
-	query := `SELECT id FROM users WHERE reset_token = $1 AND reset_token_expiry > $2`
-	row := database.DB.QueryRow(query, token, time.Now())
+	return lookupResetToken(database.DB, token, "")
+}
 
-	var userID string
-	err := row.Scan(&userID)
+// ResetPasswordWithToken cambia la contraseña y consume el token en la misma transacción,
+// de modo que cada token solo sirve una vez. Devuelve false si el token ya no es válido.
+func ResetPasswordWithToken(token, newPassword string) (bool, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	userID, err := lookupResetToken(tx, token, " FOR UPDATE")
+	if err != nil || userID == "" {
+		return false, err
+	}
+
+	query := `UPDATE users SET password = $1, reset_token = NULL, reset_token_hash = NULL, reset_token_expiry = NULL WHERE id = $2`
+	if _, err := tx.Exec(query, string(hashedPassword), userID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func lookupResetToken(q database.Executor, token, lock string) (string, error) {
+	selector, verifier, found := strings.Cut(token, ".")
+	if !found || selector == "" || verifier == "" {
+		return "", nil
+	}
+
+	query := `SELECT id, COALESCE(reset_token_hash, '') FROM users WHERE reset_token = $1 AND reset_token_expiry > $2` + lock
+	row := q.QueryRow(query, selector, time.Now())
+
+	var userID, storedHash string
+	err := row.Scan(&userID, &storedHash)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return "", nil
@@ -161,16 +471,35 @@ func VerifyResetToken(token string) (string, error) {
 		return "", err
 	}
 
+	if subtle.ConstantTimeCompare([]byte(hashToken(verifier)), []byte(storedHash)) != 1 {
+		return "", nil
+	}
+
 	return userID, nil
 }
 
+// ClearResetToken invalida cualquier enlace de recuperación pendiente, p. ej. tras un login correcto
+func ClearResetToken(userID string) error {
+	query := `UPDATE users SET reset_token = NULL, reset_token_hash = NULL, reset_token_expiry = NULL WHERE id = $1 AND reset_token IS NOT NULL`
+	_, err := database.DB.Exec(query, userID)
+	return err
+}
+
+func randomHex(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
 func UpdatePassword(userID, newPassword string) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 
-	query := `UPDATE users SET password = $1, reset_token = NULL, reset_token_expiry = NULL WHERE id = $2`
+	query := `UPDATE users SET password = $1, reset_token = NULL, reset_token_hash = NULL, reset_token_expiry = NULL WHERE id = $2`
 	_, err = database.DB.Exec(query, string(hashedPassword), userID)
 	return err
 }
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/lib/pq"
+)
+
+// Venue es un lugar que varios eventos pueden reutilizar. Los eventos guardan una copia de la
+// ubicación, el estacionamiento, la accesibilidad y la guía de transporte, que se actualiza al editar el lugar.
+type Venue struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	// Capacity es la cantidad máxima de personas; 0 si no se indicó
+	Capacity         int       `json:"capacity"`
+	Location         Location  `json:"location"`
+	ExclusiveParking bool      `json:"exclusive_parking"`
+	Accessibility    []string  `json:"accessibility"`
+	TransportGuide   string    `json:"transport_guide"`
+	Photos           []string  `json:"photos"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+const venueColumns = `id, COALESCE(user_id, ''), name, capacity, address, lat, lng, COALESCE(city, ''), COALESCE(province, ''), exclusive_parking, accessibility, COALESCE(transport_guide, ''), photos, created_at, updated_at`
+
+func scanVenue(row rowScanner) (*Venue, error) {
+	var v Venue
+	err := row.Scan(&v.ID, &v.UserID, &v.Name, &v.Capacity, &v.Location.Address, &v.Location.Lat, &v.Location.Lng, &v.Location.City, &v.Location.Province, &v.ExclusiveParking, pq.Array(&v.Accessibility), &v.TransportGuide, pq.Array(&v.Photos), &v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if v.Accessibility == nil {
+		v.Accessibility = []string{}
+	}
+	if v.Photos == nil {
+		v.Photos = []string{}
+	}
+	return &v, nil
+}
+
+func (v *Venue) Save() error {
+	v.CreatedAt = time.Now()
+	v.UpdatedAt = v.CreatedAt
+
+	query := `
+		INSERT INTO venues (id, user_id, name, capacity, address, lat, lng, city, province, exclusive_parking, accessibility, transport_guide, photos, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $14)
+	`
+	_, err := database.DB.Exec(query, v.ID, v.UserID, v.Name, v.Capacity, v.Location.Address, v.Location.Lat, v.Location.Lng, v.Location.City, v.Location.Province, v.ExclusiveParking, pq.Array(v.Accessibility), v.TransportGuide, pq.Array(v.Photos), v.CreatedAt)
+	return err
+}
+
+func GetVenueByID(id string) (*Venue, error) {
+	venue, err := scanVenue(database.DB.QueryRow(`SELECT `+venueColumns+` FROM venues WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return venue, err
+}
+
+// GetVenues lista los lugares por nombre, filtrando opcionalmente por ciudad
+func GetVenues(city string, page, limit int) ([]Venue, int, error) {
+	var total int
+	err := database.DB.QueryRow(`SELECT COUNT(*) FROM venues WHERE $1 = '' OR LOWER(city) = LOWER($1)`, city).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + venueColumns + ` FROM venues WHERE $1 = '' OR LOWER(city) = LOWER($1) ORDER BY name LIMIT $2 OFFSET $3`
+	rows, err := database.DB.Query(query, city, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	venues := []Venue{}
+	for rows.Next() {
+		venue, err := scanVenue(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		venues = append(venues, *venue)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return venues, total, nil
+}
+
+// GetVenuesByUserID son los lugares que cargó el usuario
+func GetVenuesByUserID(userID string) ([]Venue, error) {
+	rows, err := database.DB.Query(`SELECT `+venueColumns+` FROM venues WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	venues := []Venue{}
+	for rows.Next() {
+		venue, err := scanVenue(rows)
+		if err != nil {
+			return nil, err
+		}
+		venues = append(venues, *venue)
+	}
+
+	return venues, rows.Err()
+}
+
+func UpdateVenue(exec database.Executor, v *Venue) error {
+	v.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE venues
+		SET name = $1, capacity = $2, address = $3, lat = $4, lng = $5, city = $6, province = $7, exclusive_parking = $8, accessibility = $9, transport_guide = $10, photos = $11, updated_at = $12
+		WHERE id = $13
+	`
+	_, err := exec.Exec(query, v.Name, v.Capacity, v.Location.Address, v.Location.Lat, v.Location.Lng, v.Location.City, v.Location.Province, v.ExclusiveParking, pq.Array(v.Accessibility), v.TransportGuide, pq.Array(v.Photos), v.UpdatedAt, v.ID)
+	return err
+}
+
+// CountEventsByVenueID incluye los eventos archivados, que también referencian al lugar
+func CountEventsByVenueID(venueID string) (int, error) {
+	var count int
+	err := database.DB.QueryRow(`SELECT COUNT(*) FROM events WHERE venue_id = $1`, venueID).Scan(&count)
+	return count, err
+}
+
+func DeleteVenue(id string) error {
+	_, err := database.DB.Exec(`DELETE FROM venues WHERE id = $1`, id)
+	return err
+}
+
+// GetEventIDsByVenueID devuelve los eventos que usan el lugar, para actualizar su copia al editarlo
+func GetEventIDsByVenueID(exec database.Executor, venueID string) ([]string, error) {
+	rows, err := exec.Query(`SELECT id FROM events WHERE venue_id = $1`, venueID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetEventSummariesByVenueID es la cartelera pública del lugar
+func GetEventSummariesByVenueID(venueID string) ([]EventSummary, error) {
+	query := `
+		SELECT id, name, main_image_url, date_times, min_price
+		FROM events
+		WHERE venue_id = $1 AND ` + publicEventFilter + `
+		ORDER BY created_at DESC
+	`
+	rows, err := database.DB.Query(query, venueID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEventSummaries(rows)
+}
+
+// ApplyTo copia en el evento los datos del lugar
+func (v *Venue) ApplyTo(event *Event) {
+	event.VenueID = v.ID
+	event.Location = v.Location
+	event.ExclusiveParking = v.ExclusiveParking
+	event.Accessibility = v.Accessibility
+	event.TransportGuide = v.TransportGuide
+
+	// Las fechas sin cupo (sin límite) toman la capacidad del lugar, que no pueden superar.
+	// El mapa se copia porque el evento puede compartirlo con la versión anterior.
+	if v.Capacity > 0 {
+		dateTimes := make(map[string]DateTime, len(event.DateTimes))
+		for date, dateTime := range event.DateTimes {
+			if dateTime.Capacity <= 0 {
+				dateTime.Capacity = v.Capacity
+			}
+			dateTimes[date] = dateTime
+		}
+		event.DateTimes = dateTimes
+	}
+}
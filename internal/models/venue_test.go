@@ -0,0 +1,32 @@
+package models
+
+import "testing"
+
+func TestVenueApplyToLimitsUncappedDates(t *testing.T) {
+	before := &Event{DateTimes: map[string]DateTime{
+		"10/11/2026": {Time: "20:00"},
+		"11/11/2026": {Time: "20:00", Capacity: 150},
+	}}
+	after := *before
+
+	venue := &Venue{ID: "venue-1", Capacity: 300}
+	venue.ApplyTo(&after)
+
+	if got := after.DateTimes["10/11/2026"].Capacity; got != 300 {
+		t.Errorf("uncapped date capacity = %d, want the venue capacity", got)
+	}
+	if got := after.DateTimes["11/11/2026"].Capacity; got != 150 {
+		t.Errorf("capped date capacity = %d, want 150", got)
+	}
+	// La versión anterior se usa para avisar los cambios: no debe modificarse
+	if got := before.DateTimes["10/11/2026"].Capacity; got != 0 {
+		t.Errorf("previous event capacity = %d, want 0", got)
+	}
+
+	// Un lugar sin capacidad deja las fechas como estaban
+	unlimited := *before
+	(&Venue{ID: "venue-2"}).ApplyTo(&unlimited)
+	if got := unlimited.DateTimes["10/11/2026"].Capacity; got != 0 {
+		t.Errorf("capacity with an unlimited venue = %d, want 0", got)
+	}
+}
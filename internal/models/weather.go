@@ -1,15 +1,28 @@
 package models
 
+import "time"
+
 type WeatherResponse struct {
-	City struct {
-		Name string `json:"name"`
-	} `json:"city"`
-	Main struct {
-		Temp float64 `json:"temp"`
-	} `json:"main"`
-	Weather []struct {
-		Main        string `json:"main"`
-		Description string `json:"description"`
-	} `json:"weather"`
-	Link string `json:"link"`
+	City       WeatherCity        `json:"city"`
+	Main       WeatherMain        `json:"main"`
+	Weather    []WeatherCondition `json:"weather"`
+	Link       string             `json:"link"`
+	ForecastAt time.Time          `json:"forecast_at"`
+	// PrecipitationProbability va de 0 a 1
+	PrecipitationProbability float64 `json:"pop"`
+	// RainMM es la lluvia prevista en las 3 horas del pronóstico
+	RainMM float64 `json:"rain_mm"`
+}
+
+type WeatherCity struct {
+	Name string `json:"name"`
+}
+
+type WeatherMain struct {
+	Temp float64 `json:"temp"`
+}
+
+type WeatherCondition struct {
+	Main        string `json:"main"`
+	Description string `json:"description"`
 }
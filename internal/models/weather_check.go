@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/lib/pq"
+)
+
+// WeatherCheck es el resultado de revisar el pronóstico de una fecha de un evento al aire libre
+type WeatherCheck struct {
+	ID        string `json:"id"`
+	EventID   string `json:"event_id"`
+	EventDate string `json:"event_date"`
+	// Status es ok, unavailable u out_of_range, como en el clima del evento
+	Status                   string     `json:"status"`
+	ForecastAt               *time.Time `json:"forecast_at"`
+	Temperature              *float64   `json:"temperature"`
+	PrecipitationProbability *float64   `json:"precipitation_probability"`
+	RainMM                   *float64   `json:"rain_mm"`
+	// Alerts son las condiciones que superaron los umbrales (rain, heat)
+	Alerts    []string  `json:"alerts"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+func (w *WeatherCheck) Save() error {
+	if w.Alerts == nil {
+		w.Alerts = []string{}
+	}
+
+	query := `
+		INSERT INTO weather_checks (id, event_id, event_date, status, forecast_at, temperature, precipitation_probability, rain_mm, alerts, checked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := database.DB.Exec(query, w.ID, w.EventID, w.EventDate, w.Status, w.ForecastAt, w.Temperature, w.PrecipitationProbability, w.RainMM, pq.Array(w.Alerts), w.CheckedAt)
+	return err
+}
+
+// GetWeatherChecksByEventID devuelve el historial de la más nueva a la más vieja
+func GetWeatherChecksByEventID(eventID string, page, limit int) ([]WeatherCheck, int, error) {
+	var total int
+	if err := database.DB.QueryRow(`SELECT COUNT(*) FROM weather_checks WHERE event_id = $1`, eventID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id, event_id, event_date, status, forecast_at, temperature, precipitation_probability, rain_mm, alerts, checked_at
+		FROM weather_checks
+		WHERE event_id = $1
+		ORDER BY checked_at DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := database.DB.Query(query, eventID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	checks := []WeatherCheck{}
+	for rows.Next() {
+		var w WeatherCheck
+		var forecastAt sql.NullTime
+		var temperature, probability, rain sql.NullFloat64
+		err := rows.Scan(&w.ID, &w.EventID, &w.EventDate, &w.Status, &forecastAt, &temperature, &probability, &rain, pq.Array(&w.Alerts), &w.CheckedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		if forecastAt.Valid {
+			w.ForecastAt = &forecastAt.Time
+		}
+		if temperature.Valid {
+			w.Temperature = &temperature.Float64
+		}
+		if probability.Valid {
+			w.PrecipitationProbability = &probability.Float64
+		}
+		if rain.Valid {
+			w.RainMM = &rain.Float64
+		}
+		checks = append(checks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return checks, total, nil
+}
+
+// DeleteWeatherChecksBefore borra el historial revisado antes de cutoff
+func DeleteWeatherChecksBefore(cutoff time.Time) (int64, error) {
+	result, err := database.DB.Exec(`DELETE FROM weather_checks WHERE checked_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetOutdoorEventIDs devuelve los eventos al aire libre publicados
+func GetOutdoorEventIDs() ([]string, error) {
+	rows, err := database.DB.Query(`SELECT id FROM events WHERE outdoor AND status = $1`, EventStatusPublished)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,293 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/lib/pq"
+)
+
+type Webhook struct {
+	ID         string   `json:"id"`
+	UserID     string   `json:"user_id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	// Secret solo se muestra al crear el webhook
+	Secret string `json:"secret,omitempty"`
+	Active bool   `json:"active"`
+	// ConsecutiveFailures cuenta las entregas seguidas que agotaron sus reintentos
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	DisabledReason      string     `json:"disabled_reason,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+const webhookColumns = `id, user_id, url, event_types, secret, active, consecutive_failures, disabled_at, COALESCE(disabled_reason, ''), created_at, updated_at`
+
+func scanWebhook(row rowScanner) (*Webhook, error) {
+	var w Webhook
+	var disabledAt sql.NullTime
+	err := row.Scan(&w.ID, &w.UserID, &w.URL, pq.Array(&w.EventTypes), &w.Secret, &w.Active, &w.ConsecutiveFailures, &disabledAt, &w.DisabledReason, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if disabledAt.Valid {
+		w.DisabledAt = &disabledAt.Time
+	}
+	return &w, nil
+}
+
+func (w *Webhook) Save() error {
+	w.CreatedAt = time.Now()
+	w.UpdatedAt = w.CreatedAt
+	w.Active = true
+
+	query := `
+		INSERT INTO webhooks (id, user_id, url, event_types, secret, active, consecutive_failures, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, TRUE, 0, $6, $6)
+	`
+	_, err := database.DB.Exec(query, w.ID, w.UserID, w.URL, pq.Array(w.EventTypes), w.Secret, w.CreatedAt)
+	return err
+}
+
+// GetWebhooksByUserID no incluye los secretos
+func GetWebhooksByUserID(userID string) ([]Webhook, error) {
+	rows, err := database.DB.Query(`SELECT `+webhookColumns+` FROM webhooks WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhook.Secret = ""
+		webhooks = append(webhooks, *webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// GetWebhookByID devuelve el webhook con su secreto, para firmar las entregas
+func GetWebhookByID(id string) (*Webhook, error) {
+	webhook, err := scanWebhook(database.DB.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// GetActiveWebhooksFor devuelve los webhooks activos del usuario suscriptos al tipo de evento
+func GetActiveWebhooksFor(exec database.Executor, userID, eventType string) ([]Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE user_id = $1 AND active AND $2 = ANY(event_types)`
+	rows, err := exec.Query(query, userID, eventType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+type WebhookPatch struct {
+	URL        *string  `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Active     *bool    `json:"active"`
+}
+
+// UpdateWebhook aplica los campos enviados; reactivar un webhook pone en cero sus fallos
+func UpdateWebhook(id string, patch WebhookPatch) error {
+	query := `
+		UPDATE webhooks SET
+			url = COALESCE($1, url),
+			event_types = COALESCE($2, event_types),
+			active = COALESCE($3, active),
+			consecutive_failures = CASE WHEN $3 THEN 0 ELSE consecutive_failures END,
+			disabled_at = CASE WHEN $3 THEN NULL ELSE disabled_at END,
+			disabled_reason = CASE WHEN $3 THEN NULL ELSE disabled_reason END,
+			updated_at = $4
+		WHERE id = $5
+	`
+	var eventTypes interface{}
+	if patch.EventTypes != nil {
+		eventTypes = pq.Array(patch.EventTypes)
+	}
+	_, err := database.DB.Exec(query, patch.URL, eventTypes, patch.Active, time.Now(), id)
+	return err
+}
+
+func DeleteWebhook(id string) error {
+	return database.WithTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = $1`, id); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM webhooks WHERE id = $1`, id)
+		return err
+	})
+}
+
+// RecordWebhookSuccess pone en cero los fallos seguidos
+func RecordWebhookSuccess(id string) error {
+	_, err := database.DB.Exec(`UPDATE webhooks SET consecutive_failures = 0 WHERE id = $1 AND consecutive_failures > 0`, id)
+	return err
+}
+
+// RecordWebhookFailure suma una entrega fallida y desactiva el webhook al llegar a maxFailures.
+// Devuelve true si esta llamada lo desactivó.
+func RecordWebhookFailure(id string, maxFailures int, reason string) (bool, error) {
+	query := `
+		UPDATE webhooks SET
+			consecutive_failures = consecutive_failures + 1,
+			active = active AND consecutive_failures + 1 < $1,
+			disabled_at = CASE WHEN active AND consecutive_failures + 1 >= $1 THEN $2 ELSE disabled_at END,
+			disabled_reason = CASE WHEN active AND consecutive_failures + 1 >= $1 THEN $3 ELSE disabled_reason END,
+			updated_at = $2
+		WHERE id = $4
+		RETURNING disabled_at = $2
+	`
+	var disabled sql.NullBool
+	err := database.DB.QueryRow(query, maxFailures, time.Now(), reason, id).Scan(&disabled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return disabled.Valid && disabled.Bool, nil
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery es una entrada del registro de entregas; guarda el resultado del último intento
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhook_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	ResponseBody   string          `json:"response_body,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	DurationMS     int             `json:"duration_ms,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event_type, payload, status, attempts, COALESCE(response_status, 0), COALESCE(response_body, ''), COALESCE(error, ''), COALESCE(duration_ms, 0), created_at, last_attempt_at`
+
+func scanWebhookDelivery(row rowScanner) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	var payload []byte
+	var lastAttemptAt sql.NullTime
+	err := row.Scan(&d.ID, &d.WebhookID, &d.EventType, &payload, &d.Status, &d.Attempts, &d.ResponseStatus, &d.ResponseBody, &d.Error, &d.DurationMS, &d.CreatedAt, &lastAttemptAt)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+	if lastAttemptAt.Valid {
+		d.LastAttemptAt = &lastAttemptAt.Time
+	}
+	return &d, nil
+}
+
+func (d *WebhookDelivery) Save(exec database.Executor) error {
+	d.Status = DeliveryPending
+	query := `
+		INSERT INTO webhook_deliveries (id, webhook_id, event_type, payload, status, attempts, created_at)
+		VALUES ($1, $2, $3, $4, $5, 0, $6)
+	`
+	_, err := exec.Exec(query, d.ID, d.WebhookID, d.EventType, []byte(d.Payload), d.Status, d.CreatedAt)
+	return err
+}
+
+func GetWebhookDelivery(id string) (*WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(database.DB.QueryRow(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func GetWebhookDeliveries(webhookID string, page, limit int) ([]WebhookDelivery, int, error) {
+	var total int
+	if err := database.DB.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1`, webhookID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`
+	rows, err := database.DB.Query(query, webhookID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+// RecordDeliveryAttempt guarda el resultado de un intento de entrega
+func RecordDeliveryAttempt(d *WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries SET
+			status = $1, attempts = attempts + 1, response_status = NULLIF($2, 0), response_body = NULLIF($3, ''),
+			error = NULLIF($4, ''), duration_ms = $5, last_attempt_at = $6
+		WHERE id = $7
+	`
+	_, err := database.DB.Exec(query, d.Status, d.ResponseStatus, d.ResponseBody, d.Error, d.DurationMS, time.Now(), d.ID)
+	return err
+}
+
+// DeleteWebhookDeliveriesBefore borra el registro de entregas viejo
+func DeleteWebhookDeliveriesBefore(cutoff time.Time) (int64, error) {
+	result, err := database.DB.Exec(`DELETE FROM webhook_deliveries WHERE created_at < $1 AND status <> $2`, cutoff, DeliveryPending)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,234 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/jobs"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/mailer"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/whatsapp"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+const JobSendNotification = "send_notification"
+
+const (
+	ChannelEmail    = "email"
+	ChannelWhatsapp = "whatsapp"
+)
+
+// Notification es un aviso ya dirigido a un canal concreto
+type Notification struct {
+	Channel  string                 `json:"channel"`
+	UserID   string                 `json:"user_id"`
+	Type     string                 `json:"type"`
+	To       string                 `json:"to"`
+	Locale   string                 `json:"locale"`
+	Template string                 `json:"template"`
+	Data     map[string]interface{} `json:"data"`
+}
+
+// NotificationChannel es un medio por el que se puede avisar a un usuario
+type NotificationChannel interface {
+	Name() string
+	// Address devuelve la dirección del usuario en este canal, o "" si no se le puede enviar este aviso por acá
+	Address(recipient models.Recipient, template string) string
+	Send(ctx context.Context, jobID string, notification Notification) error
+}
+
+var channels = []NotificationChannel{emailChannel{}, whatsappChannel{}, inAppChannel{}}
+
+func channelByName(name string) NotificationChannel {
+	for _, channel := range channels {
+		if channel.Name() == name {
+			return channel
+		}
+	}
+	return nil
+}
+
+// IsChannel indica si name es un canal conocido, esté configurado o no
+func IsChannel(name string) bool {
+	return channelByName(name) != nil
+}
+
+// Notify encola el aviso una vez por cada canal que el usuario tiene activo para ese tipo y se le puede usar.
+// Si no sale por ningún canal externo (email o WhatsApp) se envía por email, salvo que el usuario
+// haya desactivado el email para ese tipo.
+func Notify(exec database.Executor, recipient models.Recipient, notificationType, template string, data map[string]interface{}, idempotencyKey string) error {
+	preferences, err := models.GetNotificationPreferences(exec, recipient.UserID)
+	if err != nil {
+		return err
+	}
+
+	sent := false
+	for _, channel := range channels {
+		if !Enabled(recipient, preferences, notificationType, channel.Name()) {
+			continue
+		}
+		address := channel.Address(recipient, template)
+		if address == "" {
+			continue
+		}
+
+		if err := enqueueNotification(exec, channel, address, recipient, notificationType, template, data, idempotencyKey); err != nil {
+			return err
+		}
+		if channel.Name() != ChannelInApp {
+			sent = true
+		}
+	}
+
+	if enabled, explicit := preferences[notificationType][ChannelEmail]; sent || (explicit && !enabled) {
+		return nil
+	}
+	email := emailChannel{}
+	return enqueueNotification(exec, email, email.Address(recipient, template), recipient, notificationType, template, data, idempotencyKey)
+}
+
+func enqueueNotification(exec database.Executor, channel NotificationChannel, address string, recipient models.Recipient, notificationType, template string, data map[string]interface{}, idempotencyKey string) error {
+	key := ""
+	if idempotencyKey != "" {
+		key = idempotencyKey + ":" + channel.Name()
+	}
+
+	return jobs.Enqueue(exec, jobs.NewJob{
+		Type: JobSendNotification,
+		Payload: Notification{
+			Channel:  channel.Name(),
+			UserID:   recipient.UserID,
+			Type:     notificationType,
+			To:       address,
+			Locale:   recipient.Locale,
+			Template: template,
+			Data:     data,
+		},
+		IdempotencyKey: key,
+	})
+}
+
+func sendNotification(ctx context.Context, job *jobs.Job) error {
+	var notification Notification
+	// Los números se decodifican como enteros cuando lo son, para que las plantillas puedan compararlos
+	decoder := json.NewDecoder(bytes.NewReader(job.Payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&notification); err != nil {
+		return jobs.Permanent(err)
+	}
+	for key, value := range notification.Data {
+		if number, ok := value.(json.Number); ok {
+			if n, err := number.Int64(); err == nil {
+				notification.Data[key] = n
+			} else if f, err := number.Float64(); err == nil {
+				notification.Data[key] = f
+			}
+		}
+	}
+
+	channel := channelByName(notification.Channel)
+	if channel == nil {
+		return jobs.Permanent(fmt.Errorf("unknown notification channel %q", notification.Channel))
+	}
+
+	skip, err := optedOut(notification.UserID, notification.Type, notification.Channel)
+	if err != nil || skip {
+		return err
+	}
+
+	return channel.Send(ctx, job.ID, notification)
+}
+
+type emailChannel struct{}
+
+func (emailChannel) Name() string { return ChannelEmail }
+
+func (emailChannel) Address(recipient models.Recipient, template string) string {
+	return recipient.Email
+}
+
+func (emailChannel) Send(ctx context.Context, jobID string, notification Notification) error {
+	return deliver(ctx, jobID, Email{
+		To:       notification.To,
+		Locale:   notification.Locale,
+		UserID:   notification.UserID,
+		Type:     notification.Type,
+		Template: notification.Template,
+		Data:     notification.Data,
+	})
+}
+
+// whatsappTemplates arma los parámetros posicionales de cada plantilla aprobada en WhatsApp.
+// Los avisos que no están acá solo se envían por email.
+var whatsappTemplates = map[string]func(data map[string]interface{}) []string{
+	"event_reminder": func(data map[string]interface{}) []string {
+		return textParams(data, "EventName", "Date", "Time", "Address")
+	},
+	"event_changed": func(data map[string]interface{}) []string {
+		return textParams(data, "EventName", "Date", "Time", "Address")
+	},
+	"event_cancelled": func(data map[string]interface{}) []string {
+		return textParams(data, "EventName", "Date")
+	},
+	"event_postponed": func(data map[string]interface{}) []string {
+		return textParams(data, "EventName", "Date")
+	},
+	"weather_alert": func(data map[string]interface{}) []string {
+		return textParams(data, "EventName", "Date", "Time", "Description")
+	},
+	"new_event": func(data map[string]interface{}) []string {
+		return textParams(data, "OrganizerName", "EventName", "Dates")
+	},
+}
+
+func textParams(data map[string]interface{}, keys ...string) []string {
+	params := make([]string, len(keys))
+	for i, key := range keys {
+		if value, ok := data[key]; ok && value != nil {
+			params[i] = fmt.Sprint(value)
+		}
+		// WhatsApp rechaza parámetros vacíos
+		if params[i] == "" {
+			params[i] = "-"
+		}
+	}
+	return params
+}
+
+type whatsappChannel struct{}
+
+func (whatsappChannel) Name() string { return ChannelWhatsapp }
+
+func (whatsappChannel) Address(recipient models.Recipient, template string) string {
+	if whatsapp.Default == nil {
+		return ""
+	}
+	if _, ok := whatsappTemplates[template]; !ok {
+		return ""
+	}
+	return recipient.Whatsapp
+}
+
+func (whatsappChannel) Send(ctx context.Context, jobID string, notification Notification) error {
+	params, ok := whatsappTemplates[notification.Template]
+	if !ok {
+		return jobs.Permanent(fmt.Errorf("no WhatsApp template for %q", notification.Template))
+	}
+	if whatsapp.Default == nil {
+		return jobs.Permanent(errors.New("WhatsApp is not configured"))
+	}
+
+	locale := notification.Locale
+	if locale == "" {
+		locale = mailer.DefaultLocale
+	}
+
+	_, err := whatsapp.Default.SendTemplate(ctx, notification.To, notification.Template, locale, params(notification.Data))
+	if errors.Is(err, whatsapp.ErrRejected) {
+		return jobs.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+// Package notifications encola los avisos a usuarios como trabajos y registra los handlers que los envían.
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"net/mail"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/jobs"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/mailer"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/security"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/utils"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+const JobSendEmail = "send_email"
+
+type Email struct {
+	To       string                 `json:"to"`
+	Locale   string                 `json:"locale,omitempty"`
+	UserID   string                 `json:"user_id,omitempty"`
+	Template string                 `json:"template"`
+	Data     map[string]interface{} `json:"data"`
+	// Type es el tipo de aviso; si está, el email lleva el enlace para darse de baja de ese tipo
+	Type string `json:"type,omitempty"`
+	// IdempotencyKey evita encolar dos veces el mismo aviso
+	IdempotencyKey string `json:"-"`
+	// Sensitive indica que Data contiene secretos (enlaces con token) que no deben quedar guardados
+	Sensitive bool `json:"-"`
+}
+
+func RegisterHandlers() {
+	jobs.Register(JobSendEmail, sendEmail)
+	jobs.Register(JobSendNotification, sendNotification)
+	jobs.Register(JobEventReminder, sendEventReminder)
+	jobs.Register(JobNotifyFollowers, notifyFollowers)
+}
+
+// SendEmail encola el email; exec debe ser la transacción del cambio que lo origina
+func SendEmail(exec database.Executor, email Email) error {
+	return jobs.Enqueue(exec, jobs.NewJob{
+		Type:           JobSendEmail,
+		Payload:        email,
+		IdempotencyKey: email.IdempotencyKey,
+		Sensitive:      email.Sensitive,
+	})
+}
+
+func sendEmail(ctx context.Context, job *jobs.Job) error {
+	var email Email
+	if err := json.Unmarshal(job.Payload, &email); err != nil {
+		return jobs.Permanent(err)
+	}
+	return deliver(ctx, job.ID, email)
+}
+
+// deliver envía el email en el contexto de un trabajo; jobID se usa como Message-ID
+func deliver(ctx context.Context, jobID string, email Email) error {
+	if _, err := mail.ParseAddress(email.To); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	// Sin idioma explícito se usa el guardado del usuario, o el de la cuenta con esa dirección
+	if email.Locale == "" && email.UserID != "" {
+		user, err := models.GetUserByID(email.UserID)
+		if err != nil {
+			return err
+		}
+		if user != nil {
+			email.Locale = user.Locale
+		}
+	}
+	if email.Locale == "" {
+		locale, err := models.GetUserLocale(email.To)
+		if err != nil {
+			return err
+		}
+		email.Locale = locale
+	}
+
+	var unsubscribeURL string
+	if email.Type != "" && email.UserID != "" {
+		unsubscribeURL = utils.PublicURL("/unsubscribe?token=" + security.UnsubscribeToken(email.UserID, email.Type, ChannelEmail))
+		if email.Data == nil {
+			email.Data = map[string]interface{}{}
+		}
+		email.Data["UnsubscribeURL"] = unsubscribeURL
+	}
+
+	msg, err := mailer.Render(email.Template, email.Locale, email.Data)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	msg.To = email.To
+	if unsubscribeURL != "" {
+		// Baja con un clic (RFC 8058): el cliente de correo hace un POST a la URL sin abrirla
+		msg.Headers = map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
+	}
+	// SMTP no garantiza entrega única: si el worker cae tras enviar, el reintento lleva
+	// el mismo Message-ID y los clientes de correo lo tratan como duplicado
+	msg.ID = jobID
+
+	return mailer.Default.Send(ctx, msg)
+}
@@ -0,0 +1,285 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/jobs"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/utils"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+const JobEventReminder = "event_reminder"
+
+type reminderPayload struct {
+	RegistrationID string        `json:"registration_id"`
+	Occurrence     time.Time     `json:"occurrence"`
+	Before         time.Duration `json:"before"`
+}
+
+// reminderOffsets lee REMINDER_OFFSETS, la anticipación de cada recordatorio (por defecto 7 días y 24 horas)
+func reminderOffsets() []time.Duration {
+	value := os.Getenv("REMINDER_OFFSETS")
+	if value == "" {
+		return []time.Duration{7 * 24 * time.Hour, 24 * time.Hour}
+	}
+
+	var offsets []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		offset, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || offset <= 0 {
+			log.Printf("Ignoring invalid REMINDER_OFFSETS entry %q", part)
+			continue
+		}
+		offsets = append(offsets, offset)
+	}
+	return offsets
+}
+
+func eventData(event *models.Event, date string) map[string]interface{} {
+	return map[string]interface{}{
+		"EventName": event.Name,
+		"Date":      date,
+		"Time":      event.DateTimes[date].Time,
+		"Address":   event.Location.Address,
+		"EventURL":  utils.PublicURL("/events/" + event.ID),
+	}
+}
+
+// RegistrationConfirmed encola la confirmación con la entrada y programa los recordatorios
+func RegistrationConfirmed(exec database.Executor, registration *models.Registration, event *models.Event, user *models.UserResponse) error {
+	data := eventData(event, registration.EventDate)
+	data["TicketCode"] = registration.ID
+	data["PaymentLink"] = registration.PaymentLink
+
+	err := SendEmail(exec, Email{
+		To:             user.Email,
+		Locale:         user.Locale,
+		Template:       "registration_confirmed",
+		Data:           data,
+		IdempotencyKey: "registration_confirmed:" + registration.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	recipient := models.Recipient{UserID: user.ID, Email: user.Email, Locale: user.Locale}
+	err = NotifyInApp(exec, recipient, "registration_confirmed", data, "registration_confirmed:"+registration.ID)
+	if err != nil {
+		return err
+	}
+
+	return scheduleReminders(exec, registration.ID, event, registration.EventDate)
+}
+
+// scheduleReminders programa un recordatorio por cada anticipación que todavía no pasó.
+// La clave incluye la fecha: si el evento se mueve se programan nuevos y los viejos se descartan al ejecutarse.
+func scheduleReminders(exec database.Executor, registrationID string, event *models.Event, date string) error {
+	occurrence, ok := event.OccurrenceTime(date)
+	if !ok {
+		return nil
+	}
+
+	for _, before := range reminderOffsets() {
+		runAt := occurrence.Add(-before)
+		if runAt.Before(time.Now()) {
+			continue
+		}
+
+		err := jobs.Enqueue(exec, jobs.NewJob{
+			Type:           JobEventReminder,
+			Payload:        reminderPayload{RegistrationID: registrationID, Occurrence: occurrence, Before: before},
+			RunAt:          runAt,
+			IdempotencyKey: fmt.Sprintf("event_reminder:%s:%d:%s", registrationID, occurrence.Unix(), before),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sendEventReminder(ctx context.Context, job *jobs.Job) error {
+	var payload reminderPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	// Si la inscripción se canceló o el evento cambió de fecha, este recordatorio ya no aplica
+	registration, err := models.GetRegistrationByID(payload.RegistrationID)
+	if err != nil {
+		return err
+	}
+	if registration == nil || registration.UserID == "" {
+		return nil
+	}
+
+	event, err := models.GetEventByID(registration.EventID)
+	if err != nil {
+		return err
+	}
+	// Los eventos postergados o cancelados no mandan recordatorios
+	if event == nil || event.Status != models.EventStatusPublished {
+		return nil
+	}
+	occurrence, ok := event.OccurrenceTime(registration.EventDate)
+	if !ok || !occurrence.Equal(payload.Occurrence) {
+		return nil
+	}
+
+	recipient, err := models.GetRecipient(database.DB, registration.UserID)
+	if err != nil {
+		return err
+	}
+	if recipient == nil {
+		return nil
+	}
+
+	data := eventData(event, registration.EventDate)
+	data["TicketCode"] = registration.ID
+	data["Days"] = int(payload.Before / (24 * time.Hour))
+	data["Hours"] = int(payload.Before / time.Hour)
+
+	// Se reparte en un trabajo por canal; la clave evita duplicarlos si este trabajo se reintenta
+	return Notify(database.DB, *recipient, TypeReminders, "event_reminder", data, "event_reminder:"+job.ID)
+}
+
+// EventChanged avisa a cada inscripto por sus canales si cambió el lugar o la fecha a la que se anotó,
+// y reprograma sus recordatorios si cambió la hora
+func EventChanged(exec database.Executor, before, after *models.Event) error {
+	locationChanged := before.Location.Address != after.Location.Address ||
+		before.Location.Lat != after.Location.Lat || before.Location.Lng != after.Location.Lng
+
+	registrants, err := models.GetRegistrants(exec, after.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, registrant := range registrants {
+		oldDateTime := before.DateTimes[registrant.EventDate]
+		newDateTime, stillScheduled := after.DateTimes[registrant.EventDate]
+		timeChanged := stillScheduled && oldDateTime.Time != newDateTime.Time
+
+		if !locationChanged && stillScheduled && !timeChanged {
+			continue
+		}
+
+		data := eventData(after, registrant.EventDate)
+		data["LocationChanged"] = locationChanged
+		data["OldAddress"] = before.Location.Address
+		data["DateRemoved"] = !stillScheduled
+		data["TimeChanged"] = timeChanged
+		data["OldTime"] = oldDateTime.Time
+
+		err := Notify(exec, registrant.Recipient, TypeEventChanges, "event_changed", data, fmt.Sprintf("event_changed:%s:%s", registrant.RegistrationID, after.UpdatedAt))
+		if err != nil {
+			return err
+		}
+
+		if timeChanged {
+			if err := scheduleReminders(exec, registrant.RegistrationID, after, registrant.EventDate); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// EventCancelled avisa a los inscriptos de fechas que todavía no pasaron; las inscripciones se conservan
+func EventCancelled(exec database.Executor, event *models.Event) error {
+	return notifyUpcomingRegistrants(exec, event, "event_cancelled", "event_cancelled:%s")
+}
+
+// EventPostponed avisa que el evento se postergó; un mismo evento puede postergarse más de una vez
+func EventPostponed(exec database.Executor, event *models.Event) error {
+	return notifyUpcomingRegistrants(exec, event, "event_postponed", "event_postponed:%s:"+event.UpdatedAt)
+}
+
+// notifyUpcomingRegistrants envía la plantilla con el motivo del cambio de estado; keyFormat recibe la inscripción
+func notifyUpcomingRegistrants(exec database.Executor, event *models.Event, template, keyFormat string) error {
+	registrants, err := models.GetRegistrants(exec, event.ID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, registrant := range registrants {
+		if occurrence, ok := event.OccurrenceTime(registrant.EventDate); ok && occurrence.Before(now) {
+			continue
+		}
+
+		data := eventData(event, registrant.EventDate)
+		data["Reason"] = event.StatusReason
+		err := Notify(exec, registrant.Recipient, TypeEventChanges, template, data, fmt.Sprintf(keyFormat, registrant.RegistrationID))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WeatherAlert avisa al organizador, y a los inscriptos de esa fecha si el evento lo pide, que el
+// pronóstico superó algún umbral. La clave incluye las alertas: se avisa una vez por cada combinación.
+func WeatherAlert(exec database.Executor, event *models.Event, date string, forecast *models.WeatherResponse, alerts []string) error {
+	occurrence, ok := event.OccurrenceTime(date)
+	if !ok {
+		return nil
+	}
+
+	data := eventData(event, date)
+	data["Organizer"] = false
+	data["Rain"] = contains(alerts, "rain")
+	data["Heat"] = contains(alerts, "heat")
+	data["Temperature"] = math.Round(forecast.Main.Temp)
+	data["PrecipitationProbability"] = int(math.Round(forecast.PrecipitationProbability * 100))
+	data["RainMM"] = forecast.RainMM
+	data["Description"] = ""
+	if len(forecast.Weather) > 0 {
+		data["Description"] = forecast.Weather[0].Description
+	}
+	key := fmt.Sprintf("weather_alert:%s:%d:%s", event.ID, occurrence.Unix(), strings.Join(alerts, ","))
+
+	organizer, err := models.GetRecipient(exec, event.UserID)
+	if err != nil {
+		return err
+	}
+	if organizer != nil {
+		organizerData := map[string]interface{}{}
+		for k, v := range data {
+			organizerData[k] = v
+		}
+		organizerData["Organizer"] = true
+		if err := Notify(exec, *organizer, TypeWeatherAlerts, "weather_alert", organizerData, key+":organizer"); err != nil {
+			return err
+		}
+	}
+
+	if !event.WeatherAlertsAttendees {
+		return nil
+	}
+
+	registrants, err := models.GetRegistrants(exec, event.ID)
+	if err != nil {
+		return err
+	}
+	for _, registrant := range registrants {
+		if registrant.EventDate != date || registrant.UserID == event.UserID {
+			continue
+		}
+		if err := Notify(exec, registrant.Recipient, TypeWeatherAlerts, "weather_alert", data, key+":"+registrant.RegistrationID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/jobs"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/utils"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+const JobNotifyFollowers = "notify_followers"
+
+type followersPayload struct {
+	EventID string `json:"event_id"`
+}
+
+// EventPublished encola el aviso a los seguidores del organizador. El reparto se hace en un trabajo
+// aparte para no recorrer a todos los seguidores dentro de la petición.
+func EventPublished(exec database.Executor, event *models.Event) error {
+	return jobs.Enqueue(exec, jobs.NewJob{
+		Type:           JobNotifyFollowers,
+		Payload:        followersPayload{EventID: event.ID},
+		IdempotencyKey: "new_event:" + event.ID,
+	})
+}
+
+func notifyFollowers(ctx context.Context, job *jobs.Job) error {
+	var payload followersPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	event, err := models.GetEventByID(payload.EventID)
+	if err != nil {
+		return err
+	}
+	// Si el evento se postergó, canceló o archivó antes del reparto ya no hay nada que avisar
+	if event == nil || event.Status != models.EventStatusPublished {
+		return nil
+	}
+
+	organizer, err := models.GetOrganizerProfile(event.UserID)
+	if err != nil {
+		return err
+	}
+	if organizer == nil {
+		return nil
+	}
+
+	followers, err := models.GetFollowerRecipients(database.DB, event.UserID)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"EventName":     event.Name,
+		"OrganizerName": organizer.Name,
+		"Address":       event.Location.Address,
+		"Dates":         strings.Join(sortedDates(event), ", "),
+		"EventURL":      utils.PublicURL("/events/" + event.ID),
+	}
+	// La clave por seguidor evita duplicar avisos si el trabajo se reintenta a mitad del reparto
+	for _, follower := range followers {
+		if err := Notify(database.DB, follower, TypeOrganizerUpdates, "new_event", data, "new_event:"+event.ID+":"+follower.UserID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sortedDates devuelve las fechas del evento en orden cronológico
+func sortedDates(event *models.Event) []string {
+	dates := make([]string, 0, len(event.DateTimes))
+	for date := range event.DateTimes {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool {
+		ti, _ := event.OccurrenceTime(dates[i])
+		tj, _ := event.OccurrenceTime(dates[j])
+		return ti.Before(tj)
+	})
+	return dates
+}
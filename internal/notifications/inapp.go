@@ -0,0 +1,72 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/jobs"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/mailer"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/pubsub"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+// InboxTopic es el tema de pubsub por el que se publican las notificaciones nuevas de un usuario
+func InboxTopic(userID string) string {
+	return "notifications:" + userID
+}
+
+// inAppChannel guarda el aviso en la bandeja del usuario y lo publica a sus streams abiertos
+type inAppChannel struct{}
+
+func (inAppChannel) Name() string { return ChannelInApp }
+
+func (inAppChannel) Address(recipient models.Recipient, template string) string {
+	return recipient.UserID
+}
+
+func (inAppChannel) Send(ctx context.Context, jobID string, notification Notification) error {
+	// El título es el asunto del email, así queda traducido igual que el resto de los avisos
+	msg, err := mailer.Render(notification.Template, notification.Locale, notification.Data)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	data, err := json.Marshal(notification.Data)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+
+	item := &models.UserNotification{
+		ID:        jobID,
+		UserID:    notification.To,
+		Type:      notification.Type,
+		Template:  notification.Template,
+		Title:     msg.Subject,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+	created, err := models.CreateNotification(item)
+	if err != nil || !created {
+		return err
+	}
+
+	payload, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	// La notificación ya quedó guardada; si falla la publicación el cliente la ve al recargar
+	if err := pubsub.Default.Publish(InboxTopic(item.UserID), payload); err != nil {
+		log.Printf("Error publishing notification %s: %v", item.ID, err)
+	}
+
+	return nil
+}
+
+// NotifyInApp deja el aviso solo en la bandeja, sin pasar por las preferencias: lo usan los avisos
+// de cuenta que ya se envían por email y además tienen que aparecer en la aplicación
+func NotifyInApp(exec database.Executor, recipient models.Recipient, template string, data map[string]interface{}, idempotencyKey string) error {
+	channel := inAppChannel{}
+	return enqueueNotification(exec, channel, channel.Address(recipient, template), recipient, "", template, data, idempotencyKey)
+}
@@ -0,0 +1,77 @@
+package notifications
+
+import (
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+// Tipos de aviso que el usuario puede activar o desactivar por canal. Los avisos de cuenta
+// (restablecer contraseña, cambio de email, confirmación de inscripción) no tienen tipo y siempre se envían.
+const (
+	TypeReminders        = "reminders"
+	TypeEventChanges     = "event_changes"
+	TypeOrganizerUpdates = "organizer_updates"
+	TypeWeatherAlerts    = "weather_alerts"
+)
+
+var Types = []string{TypeReminders, TypeEventChanges, TypeOrganizerUpdates, TypeWeatherAlerts}
+
+const ChannelInApp = "in_app"
+
+// PreferenceChannels son los canales que se pueden configurar por tipo de aviso
+var PreferenceChannels = []string{ChannelEmail, ChannelWhatsapp, ChannelInApp}
+
+func IsType(name string) bool {
+	return contains(Types, name)
+}
+
+func IsPreferenceChannel(name string) bool {
+	return contains(PreferenceChannels, name)
+}
+
+// Enabled resuelve si el usuario quiere recibir ese tipo de aviso por ese canal: manda la preferencia
+// guardada y, si no hay, los canales por defecto del usuario (in_app siempre está activo por defecto)
+func Enabled(recipient models.Recipient, preferences map[string]map[string]bool, notificationType, channel string) bool {
+	if enabled, ok := preferences[notificationType][channel]; ok {
+		return enabled
+	}
+	return channel == ChannelInApp || contains(recipient.Channels, channel)
+}
+
+// PreferenceMatrix devuelve todas las combinaciones de tipo y canal ya resueltas
+func PreferenceMatrix(recipient models.Recipient, preferences map[string]map[string]bool) map[string]map[string]bool {
+	matrix := map[string]map[string]bool{}
+	for _, notificationType := range Types {
+		matrix[notificationType] = map[string]bool{}
+		for _, channel := range PreferenceChannels {
+			matrix[notificationType][channel] = Enabled(recipient, preferences, notificationType, channel)
+		}
+	}
+	return matrix
+}
+
+// optedOut vuelve a consultar las preferencias al enviar, por si el usuario se dio de baja después
+// de que el aviso se encolara. Solo cuenta la baja explícita: el email de respaldo de Notify se envía
+// aunque el email no esté entre los canales por defecto.
+func optedOut(userID, notificationType, channel string) (bool, error) {
+	if notificationType == "" || userID == "" {
+		return false, nil
+	}
+
+	preferences, err := models.GetNotificationPreferences(database.DB, userID)
+	if err != nil {
+		return false, err
+	}
+
+	enabled, explicit := preferences[notificationType][channel]
+	return explicit && !enabled, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/oidc/oidcstub"
+)
+
+// Default es el proveedor configurado; nil si el login con OIDC está desactivado
+var Default *Provider
+
+// Stub es el IdP local para desarrollo, montado en /dev/oidc cuando OIDC_STUB=true
+var Stub http.Handler
+
+const stubPath = "/dev/oidc"
+
+func Init() {
+	config := Config{
+		IssuerURL:    os.Getenv("OIDC_ISSUER_URL"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+	}
+	if scopes := os.Getenv("OIDC_SCOPES"); scopes != "" {
+		config.Scopes = strings.Fields(scopes)
+	}
+
+	if os.Getenv("OIDC_STUB") == "true" {
+		if os.Getenv("GIN_MODE") == "release" {
+			log.Fatal("OIDC_STUB cannot be enabled in release mode")
+		}
+		config = stubConfig(config)
+
+		// Solo se puede entrar con estos emails (OIDC_STUB_EMAILS, separados por comas)
+		allowedEmails := os.Getenv("OIDC_STUB_EMAILS")
+		if allowedEmails == "" {
+			allowedEmails = oidcstub.DefaultEmail
+		}
+
+		stub, err := oidcstub.New(oidcstub.Options{
+			Issuer:        config.IssuerURL,
+			ClientID:      config.ClientID,
+			ClientSecret:  config.ClientSecret,
+			RedirectURL:   config.RedirectURL,
+			AllowedEmails: strings.Split(allowedEmails, ","),
+		})
+		if err != nil {
+			log.Fatalf("Error starting OIDC stub: %v", err)
+		}
+		Stub = http.StripPrefix(stubPath, stub)
+		log.Printf("OIDC stub identity provider enabled at %s", config.IssuerURL)
+	}
+
+	if config.IssuerURL == "" {
+		return
+	}
+	if config.ClientID == "" || config.RedirectURL == "" {
+		log.Fatal("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER_URL is set")
+	}
+
+	Default = NewProvider(config)
+}
+
+func stubConfig(config Config) Config {
+	baseURL := os.Getenv("OIDC_STUB_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	if config.IssuerURL == "" {
+		config.IssuerURL = baseURL + stubPath
+	}
+	if config.ClientID == "" {
+		config.ClientID = "dev-client"
+	}
+	if config.ClientSecret == "" {
+		config.ClientSecret = "dev-secret"
+	}
+	if config.RedirectURL == "" {
+		config.RedirectURL = baseURL + "/auth/oidc/callback"
+	}
+	return config
+}
+
+// RandomToken devuelve un valor aleatorio url-safe para state, nonce o code_verifier
+func RandomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// CodeChallenge calcula el code_challenge S256 de PKCE (RFC 7636)
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,226 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/oidc/oidcstub"
+)
+
+const testRedirectURL = "http://app.test/auth/oidc/callback"
+
+// newStubProvider levanta el IdP de prueba en un servidor local y un Provider apuntando a él
+func newStubProvider(t *testing.T, allowedEmails ...string) (*Provider, *httptest.Server) {
+	t.Helper()
+
+	var handler http.Handler
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	stub, err := oidcstub.New(oidcstub.Options{
+		Issuer:        server.URL,
+		ClientID:      "test-client",
+		ClientSecret:  "test-secret",
+		RedirectURL:   testRedirectURL,
+		AllowedEmails: allowedEmails,
+	})
+	if err != nil {
+		t.Fatalf("oidcstub.New: %v", err)
+	}
+	handler = stub
+
+	provider := NewProvider(Config{
+		IssuerURL:    server.URL,
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		RedirectURL:  testRedirectURL,
+	})
+	return provider, server
+}
+
+// authorize sigue la redirección del IdP sin ir a la app y devuelve la respuesta del endpoint de autorización
+func authorize(t *testing.T, provider *Provider, state, nonce, verifier, loginHint string) *http.Response {
+	t.Helper()
+
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, CodeChallenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if loginHint != "" {
+		authURL += "&login_hint=" + url.QueryEscape(loginHint)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("GET authorize: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// authorizationCode completa la autorización y devuelve el código de la redirección
+func authorizationCode(t *testing.T, provider *Provider, state, nonce, verifier, loginHint string) string {
+	t.Helper()
+
+	resp := authorize(t, provider, state, nonce, verifier, loginHint)
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+	if got := location.Query().Get("state"); got != state {
+		t.Fatalf("state = %q, want %q", got, state)
+	}
+	code := location.Query().Get("code")
+	if code == "" {
+		t.Fatal("redirect has no code")
+	}
+	return code
+}
+
+func TestLoginFlow(t *testing.T) {
+	provider, _ := newStubProvider(t, "ana@example.com")
+	ctx := context.Background()
+	verifier, _ := RandomToken()
+
+	code := authorizationCode(t, provider, "state-1", "nonce-1", verifier, "ana@example.com")
+
+	token, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+
+	if claims.Email != "ana@example.com" || !claims.EmailVerified {
+		t.Errorf("claims email = %q verified = %v", claims.Email, claims.EmailVerified)
+	}
+	if claims.Subject == "" || claims.Issuer != provider.config.IssuerURL {
+		t.Errorf("claims subject = %q issuer = %q", claims.Subject, claims.Issuer)
+	}
+}
+
+func TestStubUsesDefaultEmail(t *testing.T) {
+	provider, _ := newStubProvider(t)
+	ctx := context.Background()
+	verifier, _ := RandomToken()
+
+	code := authorizationCode(t, provider, "state", "nonce", verifier, "")
+	token, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, "nonce")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims.Email != oidcstub.DefaultEmail {
+		t.Errorf("email = %q, want %q", claims.Email, oidcstub.DefaultEmail)
+	}
+}
+
+func TestStubRejectsEmailsOutsideAllowList(t *testing.T) {
+	provider, _ := newStubProvider(t, "ana@example.com")
+	verifier, _ := RandomToken()
+
+	resp := authorize(t, provider, "state", "nonce", verifier, "victim@example.com")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("authorize status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	// Sin lista explícita solo se permite el email por defecto
+	provider, _ = newStubProvider(t)
+	resp = authorize(t, provider, "state", "nonce", verifier, "victim@example.com")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("authorize without allow-list status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestVerifyIDTokenRejectsWrongNonce(t *testing.T) {
+	provider, _ := newStubProvider(t)
+	ctx := context.Background()
+	verifier, _ := RandomToken()
+
+	code := authorizationCode(t, provider, "state", "nonce", verifier, "")
+	token, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if _, err := provider.VerifyIDToken(ctx, token.IDToken, "other-nonce"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("VerifyIDToken err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestVerifyIDTokenRejectsOtherAudience(t *testing.T) {
+	provider, server := newStubProvider(t)
+	ctx := context.Background()
+	verifier, _ := RandomToken()
+
+	code := authorizationCode(t, provider, "state", "nonce", verifier, "")
+	token, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	other := NewProvider(Config{IssuerURL: server.URL, ClientID: "other-client", RedirectURL: testRedirectURL})
+	if _, err := other.VerifyIDToken(ctx, token.IDToken, "nonce"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("VerifyIDToken err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	provider, _ := newStubProvider(t)
+	verifier, _ := RandomToken()
+
+	code := authorizationCode(t, provider, "state", "nonce", verifier, "")
+	if _, err := provider.Exchange(context.Background(), code, "wrong-verifier"); err == nil {
+		t.Error("Exchange with a wrong PKCE verifier succeeded")
+	}
+}
+
+func TestExchangeRejectsReusedCode(t *testing.T) {
+	provider, _ := newStubProvider(t)
+	ctx := context.Background()
+	verifier, _ := RandomToken()
+
+	code := authorizationCode(t, provider, "state", "nonce", verifier, "")
+	if _, err := provider.Exchange(ctx, code, verifier); err != nil {
+		t.Fatalf("first Exchange: %v", err)
+	}
+	if _, err := provider.Exchange(ctx, code, verifier); err == nil {
+		t.Error("second Exchange with the same code succeeded")
+	}
+}
+
+func TestExchangeRejectsWrongClientSecret(t *testing.T) {
+	provider, server := newStubProvider(t)
+	verifier, _ := RandomToken()
+
+	code := authorizationCode(t, provider, "state", "nonce", verifier, "")
+	other := NewProvider(Config{IssuerURL: server.URL, ClientID: "test-client", ClientSecret: "wrong", RedirectURL: testRedirectURL})
+	if _, err := other.Exchange(context.Background(), code, verifier); err == nil {
+		t.Error("Exchange with a wrong client secret succeeded")
+	}
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	_, server := newStubProvider(t)
+
+	provider := NewProvider(Config{IssuerURL: server.URL + "/", ClientID: "test-client", RedirectURL: testRedirectURL})
+	if _, err := provider.Discovery(context.Background()); err == nil {
+		t.Error("Discovery accepted an issuer that does not match")
+	}
+}
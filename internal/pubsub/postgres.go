@@ -0,0 +1,95 @@
+package pubsub
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// maxNotifyPayload es el límite de Postgres para el payload de NOTIFY (8000 bytes), con margen
+const maxNotifyPayload = 7900
+
+// PostgresBroker reparte los mensajes entre todas las réplicas con LISTEN/NOTIFY. Cada réplica
+// escucha solo los temas que tienen suscriptores locales y los reparte con un MemoryBroker.
+type PostgresBroker struct {
+	db       *sql.DB
+	listener *pq.Listener
+	local    *MemoryBroker
+
+	mu        sync.Mutex
+	listeners map[string]int
+}
+
+func NewPostgresBroker(db *sql.DB, connString string) *PostgresBroker {
+	broker := &PostgresBroker{
+		db:        db,
+		local:     NewMemoryBroker(),
+		listeners: map[string]int{},
+	}
+	broker.listener = pq.NewListener(connString, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Pub/sub listener: %v", err)
+		}
+	})
+
+	go broker.dispatch()
+	return broker
+}
+
+func (b *PostgresBroker) dispatch() {
+	for {
+		select {
+		case n := <-b.listener.Notify:
+			// nil indica que se recuperó la conexión; lo publicado mientras tanto se perdió
+			if n != nil {
+				b.local.Publish(n.Channel, []byte(n.Extra))
+			}
+		case <-time.After(90 * time.Second):
+			go b.listener.Ping()
+		}
+	}
+}
+
+func (b *PostgresBroker) Publish(topic string, payload []byte) error {
+	if len(payload) > maxNotifyPayload {
+		return fmt.Errorf("pubsub: payload of %d bytes exceeds the NOTIFY limit", len(payload))
+	}
+	_, err := b.db.Exec(`SELECT pg_notify($1, $2)`, topic, string(payload))
+	return err
+}
+
+func (b *PostgresBroker) Subscribe(topic string) (<-chan []byte, func()) {
+	messages, cancelLocal := b.local.Subscribe(topic)
+
+	b.mu.Lock()
+	b.listeners[topic]++
+	if b.listeners[topic] == 1 {
+		if err := b.listener.Listen(topic); err != nil && err != pq.ErrChannelAlreadyOpen {
+			log.Printf("Error listening on %s: %v", topic, err)
+		}
+	}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			cancelLocal()
+
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.listeners[topic]--
+			if b.listeners[topic] == 0 {
+				delete(b.listeners, topic)
+				if err := b.listener.Unlisten(topic); err != nil && err != pq.ErrChannelNotOpen {
+					log.Printf("Error unlistening %s: %v", topic, err)
+				}
+			}
+		})
+	}
+
+	return messages, cancel
+}
@@ -0,0 +1,86 @@
+// Package pubsub reparte mensajes entre las conexiones abiertas (p. ej. streams SSE) por tema.
+package pubsub
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+type Broker interface {
+	Publish(topic string, payload []byte) error
+	// Subscribe devuelve los mensajes del tema hasta que se llame a cancel
+	Subscribe(topic string) (messages <-chan []byte, cancel func())
+}
+
+// Default reparte por defecto dentro de este proceso, así que con varias instancias cada cliente
+// solo recibe lo publicado por la instancia a la que está conectado; ver Init
+var Default Broker = NewMemoryBroker()
+
+// Init elige el broker según PUBSUB_DRIVER: memory (por defecto, una sola instancia) o postgres
+// (LISTEN/NOTIFY, para varias réplicas). Debe llamarse después de database.InitDB.
+func Init() {
+	switch os.Getenv("PUBSUB_DRIVER") {
+	case "", "memory":
+		Default = NewMemoryBroker()
+	case "postgres":
+		Default = NewPostgresBroker(database.DB, database.ConnectionString())
+		log.Println("Pub/sub messages are shared between replicas through Postgres LISTEN/NOTIFY")
+	default:
+		log.Fatalf("Unknown PUBSUB_DRIVER %q (use memory or postgres)", os.Getenv("PUBSUB_DRIVER"))
+	}
+}
+
+// subscriberBuffer es cuántos mensajes se guardan para un suscriptor lento antes de descartarlos
+const subscriberBuffer = 16
+
+type MemoryBroker struct {
+	mu     sync.RWMutex
+	topics map[string]map[chan []byte]struct{}
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{topics: map[string]map[chan []byte]struct{}{}}
+}
+
+func (b *MemoryBroker) Publish(topic string, payload []byte) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for subscriber := range b.topics[topic] {
+		select {
+		case subscriber <- payload:
+		default:
+			// El suscriptor no da abasto; pierde el mensaje en vez de frenar a los demás
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(topic string) (<-chan []byte, func()) {
+	subscriber := make(chan []byte, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.topics[topic] == nil {
+		b.topics[topic] = map[chan []byte]struct{}{}
+	}
+	b.topics[topic][subscriber] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.topics[topic], subscriber)
+			if len(b.topics[topic]) == 0 {
+				delete(b.topics, topic)
+			}
+			b.mu.Unlock()
+			close(subscriber)
+		})
+	}
+
+	return subscriber, cancel
+}
@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/pubsub"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/utils"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/gin-gonic/gin"
+)
+
+type availabilitySnapshot struct {
+	EventID string                    `json:"event_id"`
+	Status  string                    `json:"status"`
+	Dates   []models.DateAvailability `json:"dates"`
+	// Deleted avisa a los streams abiertos que el evento se borró
+	Deleted bool `json:"deleted,omitempty"`
+
+	event *models.Event
+}
+
+func availabilityTopic(eventID string) string {
+	return "availability:" + eventID
+}
+
+func loadAvailability(eventID string) (*availabilitySnapshot, error) {
+	event, err := models.GetEventByID(eventID)
+	if err != nil || event == nil {
+		return nil, err
+	}
+
+	dates, err := models.GetEventAvailability(database.DB, event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &availabilitySnapshot{EventID: eventID, Status: event.Status, Dates: dates, event: event}, nil
+}
+
+// publishAvailability reparte el estado actual de las fechas a los streams abiertos.
+// Se llama después de confirmar la transacción; si falla, los clientes se ponen al día al reconectarse.
+func publishAvailability(eventID string) {
+	snapshot, err := loadAvailability(eventID)
+	if err != nil {
+		log.Printf("Error loading availability for event %s: %v", eventID, err)
+		return
+	}
+	if snapshot == nil {
+		snapshot = &availabilitySnapshot{EventID: eventID, Dates: []models.DateAvailability{}, Deleted: true}
+	}
+
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return
+	}
+	if err := pubsub.Default.Publish(availabilityTopic(eventID), payload); err != nil {
+		log.Printf("Error publishing availability for event %s: %v", eventID, err)
+	}
+}
+
+func getEventAvailability(c *gin.Context) {
+	snapshot, err := loadAvailability(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve availability", "details": err.Error()})
+		return
+	}
+	if snapshot == nil || !canViewEvent(c, snapshot.event) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// streamEventAvailability envía por Server-Sent Events el estado de todas las fechas al conectarse
+// y cada vez que cambia (evento "availability"). Si el evento se borra envía un último estado con
+// deleted=true y cierra el stream.
+func streamEventAvailability(c *gin.Context) {
+	eventID := c.Param("id")
+
+	// Suscribirse antes de leer la base para no perder cambios en el medio
+	messages, cancel := pubsub.Default.Subscribe(availabilityTopic(eventID))
+	defer cancel()
+
+	snapshot, err := loadAvailability(eventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve availability", "details": err.Error()})
+		return
+	}
+	if snapshot == nil || !canViewEvent(c, snapshot.event) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	utils.StartSSE(c.Writer)
+	utils.WriteSSE(c.Writer, "", "availability", snapshot)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(utils.SSEKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			utils.WriteSSEKeepAlive(c.Writer)
+			c.Writer.Flush()
+		case payload, ok := <-messages:
+			if !ok {
+				return
+			}
+			var update availabilitySnapshot
+			if err := json.Unmarshal(payload, &update); err != nil {
+				continue
+			}
+			utils.WriteSSE(c.Writer, "", "availability", update)
+			c.Writer.Flush()
+			if update.Deleted {
+				return
+			}
+		}
+	}
+}
+
+// validateDateTimes rechaza cupos negativos; responde el error y devuelve false si no son válidos
+func validateDateTimes(c *gin.Context, dateTimes map[string]models.DateTime) bool {
+	for date, dateTime := range dateTimes {
+		if dateTime.Capacity < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "capacity cannot be negative", "date": date})
+			return false
+		}
+	}
+	return true
+}
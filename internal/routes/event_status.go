@@ -0,0 +1,189 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// canViewEvent: los borradores solo los ve su organizador
+func canViewEvent(c *gin.Context, event *models.Event) bool {
+	return event.IsVisible() || c.GetString("userID") == event.UserID
+}
+
+// applyInitialStatus valida el estado con el que se crea el evento: borrador por defecto, o publicado.
+// publish_at programa la publicación de un borrador.
+func applyInitialStatus(c *gin.Context, event *models.Event) bool {
+	switch event.Status {
+	case "":
+		event.Status = models.EventStatusDraft
+	case models.EventStatusDraft, models.EventStatusPublished:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be draft or published"})
+		return false
+	}
+	event.StatusReason = ""
+
+	if event.PublishAt != nil {
+		if event.Status != models.EventStatusDraft {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at only applies to drafts"})
+			return false
+		}
+		if !event.PublishAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at must be in the future"})
+			return false
+		}
+	}
+
+	if event.Status == models.EventStatusPublished && !event.HasUpcomingDates() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The event has no upcoming dates"})
+		return false
+	}
+	return true
+}
+
+// checkTransition responde el error y devuelve false si el evento no puede pasar al estado pedido
+func checkTransition(c *gin.Context, event *models.Event, status string) bool {
+	if !models.CanTransition(event.Status, status) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Invalid status transition", "status": event.Status, "allowed_transitions": event.AllowedTransitions()})
+		return false
+	}
+
+	switch status {
+	case models.EventStatusPublished:
+		if !event.HasUpcomingDates() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The event has no upcoming dates"})
+			return false
+		}
+	case models.EventStatusArchived:
+		// Archivar no avisa a nadie: con fechas pendientes primero hay que cancelarlo
+		if event.Status != models.EventStatusCancelled && event.HasUpcomingDates() {
+			c.JSON(http.StatusConflict, gin.H{"error": "The event still has upcoming dates, cancel it before archiving"})
+			return false
+		}
+	}
+	return true
+}
+
+// ownEvent carga el evento y comprueba que lo haya creado el usuario autenticado
+func ownEvent(c *gin.Context) (*models.Event, bool) {
+	event, err := models.GetEventByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event", "details": err.Error()})
+		return nil, false
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+	if event.UserID != c.GetString("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to modify this event"})
+		return nil, false
+	}
+	return event, true
+}
+
+// changeEventStatus aplica una transición ya validada y responde el evento actualizado
+func changeEventStatus(c *gin.Context, event *models.Event, status, reason, message string) {
+	updated, err := services.ChangeEventStatus(event, status, reason)
+	if errors.Is(err, services.ErrStatusChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The event status changed, reload it and try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event status", "details": err.Error()})
+		return
+	}
+
+	publishAvailability(event.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "event": updated})
+}
+
+// updateEventStatus cambia el estado del evento. En un borrador, publish_at programa la publicación
+// (con status "published") y status "draft" quita la programación.
+func updateEventStatus(c *gin.Context) {
+	var input struct {
+		Status    string     `json:"status" binding:"required"`
+		Reason    string     `json:"reason"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsEventStatus(input.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of draft, published, postponed, cancelled or archived"})
+		return
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if len(input.Reason) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason cannot exceed 500 characters"})
+		return
+	}
+
+	event, ok := ownEvent(c)
+	if !ok {
+		return
+	}
+
+	// Programar la publicación no cambia el estado: el evento sigue siendo un borrador hasta esa hora
+	scheduling := input.Status == models.EventStatusDraft || input.PublishAt != nil
+	if event.Status == models.EventStatusDraft && scheduling {
+		if input.Status == models.EventStatusDraft {
+			input.PublishAt = nil
+		} else if !input.PublishAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at must be in the future"})
+			return
+		}
+
+		changed, err := models.SchedulePublish(event.ID, input.PublishAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule event", "details": err.Error()})
+			return
+		}
+		if !changed {
+			c.JSON(http.StatusConflict, gin.H{"error": "The event status changed, reload it and try again"})
+			return
+		}
+		event.PublishAt = input.PublishAt
+		c.JSON(http.StatusOK, gin.H{"message": "Event publishing schedule updated", "event": event})
+		return
+	}
+	if input.PublishAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at only applies to drafts"})
+		return
+	}
+
+	if !checkTransition(c, event, input.Status) {
+		return
+	}
+
+	// El motivo solo se muestra en los eventos cancelados o postergados
+	if input.Status != models.EventStatusCancelled && input.Status != models.EventStatusPostponed {
+		input.Reason = ""
+	}
+	changeEventStatus(c, event, input.Status, input.Reason, "Event status updated successfully")
+}
+
+// getMyEvents lista los eventos del organizador autenticado en cualquier estado, borradores incluidos
+func getMyEvents(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && !models.IsEventStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of draft, published, postponed, cancelled or archived"})
+		return
+	}
+
+	events, err := models.GetEventSummariesByOrganizer(c.GetString("userID"), status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
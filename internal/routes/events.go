@@ -1,12 +1,19 @@
 package routes
 
 import (
+	"context"
+	"database/sql"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/middleware"
 	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/notifications"
 	"github.com/AgusMolinaCode/restApi-Go.git/internal/services"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/webhooks"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -20,6 +27,9 @@ func getEvents(c *gin.Context) {
 	c.JSON(http.StatusOK, events)
 }
 
+// weatherDeadline es lo máximo que se espera a los pronósticos de todas las fechas
+const weatherDeadline = 3 * time.Second
+
 func getEventByID(c *gin.Context) {
 	id := c.Param("id")
 	event, err := models.GetEventByID(id)
@@ -27,58 +37,43 @@ func getEventByID(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event", "details": err.Error()})
 		return
 	}
-	if event == nil {
+
+	respondEventWithWeather(c, event)
+}
+
+// respondEventWithWeather responde el evento junto al pronóstico de sus próximas fechas
+func respondEventWithWeather(c *gin.Context, event *models.Event) {
+	if event == nil || !canViewEvent(c, event) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 		return
 	}
 
-	// Obtener la primera fecha disponible
-	var firstAvailableDate string
-	for date, dateTime := range event.DateTimes {
-		if dateTime.Status == "disponibles" || dateTime.Status == "pocas unidades" {
-			firstAvailableDate = date
-			break
-		}
-	}
+	// El clima es opcional: si el proveedor falla o tarda, el evento se devuelve igual
+	ctx, cancel := context.WithTimeout(c.Request.Context(), weatherDeadline)
+	defer cancel()
+
+	c.JSON(http.StatusOK, gin.H{
+		"event":   event,
+		"weather": services.GetEventWeather(ctx, event),
+	})
+}
 
-	if firstAvailableDate == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No available dates"})
+func createEvent(c *gin.Context) {
+	var event models.Event
+	if err := c.ShouldBindJSON(&event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Calcular los días restantes para el primer evento disponible
-	eventTime, err := time.Parse("02/01/2006", firstAvailableDate) // Asegúrate de que el formato sea correcto
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid event date", "details": err.Error()})
+	if !validateDateTimes(c, event.DateTimes) {
 		return
 	}
-	daysUntilEvent := int(time.Until(eventTime).Hours() / 24)
-
-	// Obtener el clima solo si faltan 7 días o menos
-	if daysUntilEvent <= 7 {
-		weather, err := services.GetWeather(event.Location.Lat, event.Location.Lng, firstAvailableDate)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve weather", "details": err.Error()})
-			return
-		}
 
-		// Incluir la información del clima en la respuesta
-		c.JSON(http.StatusOK, gin.H{
-			"event":   event,
-			"weather": weather,
-		})
-	} else {
-		// Solo devolver los datos del evento
-		c.JSON(http.StatusOK, gin.H{
-			"event": event,
-		})
+	if !applyInitialStatus(c, &event) {
+		return
 	}
-}
 
-func createEvent(c *gin.Context) {
-	var event models.Event
-	if err := c.ShouldBindJSON(&event); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !resolveEventLocation(c, &event, nil) {
 		return
 	}
 
@@ -96,7 +91,17 @@ func createEvent(c *gin.Context) {
 	event.CreatedAt = time.Now().Format(time.RFC3339)
 	event.UpdatedAt = event.CreatedAt
 
-	if err := event.Save(); err != nil {
+	// El aviso a los seguidores se encola junto con el evento; los borradores avisan al publicarse
+	err := database.WithTx(func(tx *sql.Tx) error {
+		if err := event.Save(tx); err != nil {
+			return err
+		}
+		if event.Status == models.EventStatusPublished {
+			return notifications.EventPublished(tx, &event)
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event", "details": err.Error()})
 		return
 	}
@@ -126,6 +131,10 @@ func updateEventByID(c *gin.Context) {
 		return
 	}
 
+	if !validateDateTimes(c, updatedEvent.DateTimes) {
+		return
+	}
+
 	// Validar que si se proporciona un título de pago, también se proporcione un enlace, y viceversa
 	for title, payment := range updatedEvent.PaymentLink {
 		if title == "" || payment.Link == "" {
@@ -145,17 +154,48 @@ func updateEventByID(c *gin.Context) {
 		return
 	}
 
+	// Los eventos cancelados o archivados se conservan como registro y ya no se editan
+	if event.Status == models.EventStatusCancelled || event.Status == models.EventStatusArchived {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cancelled or archived events cannot be edited", "status": event.Status})
+		return
+	}
+
+	if !resolveEventLocation(c, &updatedEvent, &event.Location) {
+		return
+	}
+
+	updatedEvent.ID = id
+	// El dueño no cambia aunque el cuerpo traiga otro user_id
+	updatedEvent.UserID = event.UserID
+	// El estado solo cambia con PUT /events/:id/status
+	updatedEvent.Status = event.Status
+	updatedEvent.PublishAt = event.PublishAt
+	updatedEvent.StatusReason = event.StatusReason
 	updatedEvent.UpdatedAt = time.Now().Format(time.RFC3339)
 
-	err = models.UpdateEventByID(id, updatedEvent)
+	// Los avisos a los inscriptos se encolan en la misma transacción que el cambio
+	err = database.WithTx(func(tx *sql.Tx) error {
+		if err := models.UpdateEventByID(tx, id, updatedEvent); err != nil {
+			return err
+		}
+		if err := notifications.EventChanged(tx, event, &updatedEvent); err != nil {
+			return err
+		}
+		return webhooks.Dispatch(tx, event.UserID, webhooks.EventEventUpdated, gin.H{"event": updatedEvent})
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event", "details": err.Error()})
 		return
 	}
 
+	// El cupo o el estado de las fechas pueden haber cambiado
+	publishAvailability(id)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Event updated successfully"})
 }
 
+// deleteEventByID borra los borradores, que no tienen inscripciones. Los demás eventos se archivan
+// para conservar las inscripciones y el historial; si todavía tienen fechas hay que cancelarlos antes.
 func deleteEventByID(c *gin.Context) {
 	id := c.Param("id")
 	userID, _ := c.Get("userID")
@@ -171,12 +211,21 @@ func deleteEventByID(c *gin.Context) {
 		return
 	}
 
-	err = models.DeleteEventByID(id)
-	if err != nil {
+	if event.Status != models.EventStatusDraft {
+		if !checkTransition(c, event, models.EventStatusArchived) {
+			return
+		}
+		changeEventStatus(c, event, models.EventStatusArchived, "", "Event archived successfully")
+		return
+	}
+
+	if err := models.DeleteEventByID(database.DB, id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event", "details": err.Error()})
 		return
 	}
 
+	publishAvailability(id)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Event deleted successfully"})
 }
 
@@ -194,6 +243,30 @@ func registerForEvent(c *gin.Context) {
 		return
 	}
 
+	event, err := models.GetEventByID(eventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event", "details": err.Error()})
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !event.AcceptsRegistrations() {
+		c.JSON(http.StatusConflict, gin.H{"error": "The event is not open for registration", "status": event.Status})
+		return
+	}
+	if _, scheduled := event.DateTimes[registrationData.EventDate]; !scheduled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The event is not scheduled on that date"})
+		return
+	}
+
+	user, err := models.GetUserByID(userID.(string))
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
 	// Verificar si el usuario ya está registrado
 	exists, err := models.IsUserRegisteredForEvent(eventID, userID.(string))
 	if err != nil {
@@ -210,16 +283,52 @@ func registerForEvent(c *gin.Context) {
 		ID:          uuid.New().String(),
 		EventID:     eventID,
 		UserID:      userID.(string),
-		Whatsapp:    "+1234567890", // Obtener de la base de datos o del contexto
+		Whatsapp:    user.Whatsapp,
 		CreatedAt:   time.Now().Format(time.RFC3339),
 		EventDate:   registrationData.EventDate,
 		PaymentLink: registrationData.PaymentLink,
 	}
 
-	if err := registration.Save(); err != nil {
+	// La confirmación y los recordatorios se encolan junto con la inscripción
+	var seat string
+	err = database.WithTx(func(tx *sql.Tx) error {
+		seat, err = models.ReserveSeat(tx, eventID, registration.UserID, registration.EventDate)
+		if err != nil || seat != models.SeatReserved {
+			return err
+		}
+
+		if err := registration.Save(tx); err != nil {
+			return err
+		}
+		if err := notifications.RegistrationConfirmed(tx, &registration, event, user); err != nil {
+			return err
+		}
+		return webhooks.Dispatch(tx, event.UserID, webhooks.EventRegistrationCreated, webhooks.RegistrationData(&registration, event, user))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register for event", "details": err.Error()})
 		return
 	}
+	// Lo comprobado antes de la transacción pudo cambiar hasta tomar el bloqueo
+	switch seat {
+	case models.SeatEventNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	case models.SeatRegistrationClosed:
+		c.JSON(http.StatusConflict, gin.H{"error": "The event is not open for registration"})
+		return
+	case models.SeatDateNotScheduled:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The event is not scheduled on that date"})
+		return
+	case models.SeatAlreadyRegistered:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User already registered for this event"})
+		return
+	case models.SeatSoldOut:
+		c.JSON(http.StatusConflict, gin.H{"error": "This date is sold out"})
+		return
+	}
+
+	publishAvailability(eventID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User registered for event", "registration": registration})
 }
@@ -228,13 +337,37 @@ func cancelRegistration(c *gin.Context) {
 	eventID := c.Param("id")
 	userID, _ := c.Get("userID")
 
-	// Cancelar el registro del usuario en el evento
-	err := models.DeleteRegistration(eventID, userID.(string))
+	event, err := models.GetEventByID(eventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event", "details": err.Error()})
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	user, err := models.GetUserByID(userID.(string))
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Cancelar el registro del usuario en el evento y avisar al organizador
+	err = database.WithTx(func(tx *sql.Tx) error {
+		registration, err := models.DeleteRegistration(tx, eventID, user.ID)
+		if err != nil || registration == nil {
+			return err
+		}
+		return webhooks.Dispatch(tx, event.UserID, webhooks.EventRegistrationCancelled, webhooks.RegistrationData(registration, event, user))
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel registration", "details": err.Error()})
 		return
 	}
 
+	publishAvailability(eventID)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Registration cancelled"})
 }
 
@@ -367,6 +500,28 @@ func getEventsByName(c *gin.Context) {
 	c.JSON(http.StatusOK, events)
 }
 
+func getEventsByLocation(c *gin.Context) {
+	city := strings.TrimSpace(c.Query("city"))
+	province := strings.TrimSpace(c.Query("province"))
+	if city == "" && province == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "city or province is required"})
+		return
+	}
+
+	events, err := models.GetEventsByLocation(city, province)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events", "details": err.Error()})
+		return
+	}
+
+	if len(events) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No events found for the specified location"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
 func getEventSummaries(c *gin.Context) {
 	limit := 10 // Puedes ajustar el límite según tus necesidades
 	page := c.Query("page")
@@ -389,3 +544,28 @@ func getEventSummaries(c *gin.Context) {
 
 	c.JSON(http.StatusOK, summaries)
 }
+
+// getEventWeatherChecks es el historial de revisiones del clima; solo lo ve el organizador
+func getEventWeatherChecks(c *gin.Context) {
+	eventID := c.Param("id")
+	userID, _ := c.Get("userID")
+
+	event, err := models.GetEventByID(eventID)
+	if err != nil || event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if event.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the organizer can view the weather checks"})
+		return
+	}
+
+	page, limit := middleware.PaginationParams(c, 20, 100)
+	checks, total, err := models.GetWeatherChecksByEventID(eventID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve weather checks", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"outdoor": event.Outdoor, "checks": checks, "page": page, "limit": limit, "total": total})
+}
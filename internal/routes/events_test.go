@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// useFakeWeather configura el proveedor falso mientras dura el test
+func useFakeWeather(t *testing.T) {
+	t.Helper()
+	previous := services.Weather
+	services.Weather = services.FakeWeather{}
+	t.Cleanup(func() { services.Weather = previous })
+}
+
+// eventWithDates arma un evento en Buenos Aires con una fecha por cada desplazamiento en días
+func eventWithDates(days ...int) *models.Event {
+	event := &models.Event{
+		ID:        "11111111-1111-4111-8111-111111111111",
+		UserID:    "22222222-2222-4222-8222-222222222222",
+		Status:    models.EventStatusPublished,
+		Location:  models.Location{Address: "Av. Siempre Viva 742", Lat: -34.60, Lng: -58.38},
+		DateTimes: map[string]models.DateTime{},
+	}
+	today := time.Now().In(models.EventTimezone())
+	for _, offset := range days {
+		date := today.AddDate(0, 0, offset).Format("02/01/2006")
+		event.DateTimes[date] = models.DateTime{Time: "20:00", Status: models.DateStatusAvailable}
+	}
+	return event
+}
+
+type eventResponse struct {
+	Event   models.Event          `json:"event"`
+	Weather services.EventWeather `json:"weather"`
+}
+
+func respondEvent(t *testing.T, event *models.Event, userID string) (int, eventResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/events/"+event.ID, nil)
+	if userID != "" {
+		c.Set("userID", userID)
+	}
+
+	respondEventWithWeather(c, event)
+
+	var body eventResponse
+	if recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid response body: %v", err)
+		}
+	}
+	return recorder.Code, body
+}
+
+func TestEventWeatherWithFakeProvider(t *testing.T) {
+	useFakeWeather(t)
+	t.Setenv("WEATHER_FAKE_CONDITION", "rain")
+
+	status, body := respondEvent(t, eventWithDates(1, 2, 30), "")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	if body.Weather.Status != services.WeatherOK {
+		t.Errorf("weather status = %q, want %q", body.Weather.Status, services.WeatherOK)
+	}
+	if len(body.Weather.Dates) != 3 {
+		t.Fatalf("weather dates = %d, want 3", len(body.Weather.Dates))
+	}
+
+	for _, date := range body.Weather.Dates[:2] {
+		if date.Status != services.WeatherOK || date.Forecast == nil {
+			t.Fatalf("date %s status = %q forecast = %v", date.Date, date.Status, date.Forecast)
+		}
+		if len(date.Forecast.Weather) == 0 || date.Forecast.Weather[0].Main != "Rain" {
+			t.Errorf("date %s forecast = %+v, want rain", date.Date, date.Forecast.Weather)
+		}
+	}
+	// Más allá de los 5 días no hay pronóstico
+	if last := body.Weather.Dates[2]; last.Status != services.WeatherOutOfRange || last.Forecast != nil {
+		t.Errorf("date %s status = %q, want %q", last.Date, last.Status, services.WeatherOutOfRange)
+	}
+}
+
+func TestEventWeatherIsDeterministic(t *testing.T) {
+	useFakeWeather(t)
+
+	event := eventWithDates(1, 2, 3)
+	_, first := respondEvent(t, event, "")
+	_, second := respondEvent(t, event, "")
+	for i := range first.Weather.Dates {
+		a, b := first.Weather.Dates[i].Forecast, second.Weather.Dates[i].Forecast
+		if a == nil || b == nil || a.Weather[0] != b.Weather[0] || a.Main.Temp != b.Main.Temp {
+			t.Errorf("date %s forecasts differ: %+v / %+v", first.Weather.Dates[i].Date, a, b)
+		}
+	}
+}
+
+func TestEventWeatherWithoutCoordinates(t *testing.T) {
+	useFakeWeather(t)
+
+	event := eventWithDates(1)
+	event.Location.Lat, event.Location.Lng = 0, 0
+
+	status, body := respondEvent(t, event, "")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	if body.Weather.Status != services.WeatherUnavailable {
+		t.Errorf("weather status = %q, want %q", body.Weather.Status, services.WeatherUnavailable)
+	}
+}
+
+func TestDraftEventIsOnlyVisibleToOrganizer(t *testing.T) {
+	useFakeWeather(t)
+
+	event := eventWithDates(1)
+	event.Status = models.EventStatusDraft
+
+	if status, _ := respondEvent(t, event, ""); status != http.StatusNotFound {
+		t.Errorf("anonymous status = %d, want %d", status, http.StatusNotFound)
+	}
+	if status, _ := respondEvent(t, event, event.UserID); status != http.StatusOK {
+		t.Errorf("organizer status = %d, want %d", status, http.StatusOK)
+	}
+}
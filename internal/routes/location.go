@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const geocodeDeadline = 5 * time.Second
+
+// resolveLocation completa las coordenadas a partir de la dirección si no vinieron, valida sus rangos
+// y obtiene la ciudad y la provincia. Al editar, previous es la ubicación guardada: lo que no cambió
+// se conserva sin volver a consultar al geocodificador, y si cambió la dirección pero llegaron las
+// mismas coordenadas se vuelven a buscar. Responde el error y devuelve false si no se puede.
+func resolveLocation(c *gin.Context, location *models.Location, previous *models.Location) bool {
+	location.Address = strings.TrimSpace(location.Address)
+	if location.Address == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "location.address is required"})
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), geocodeDeadline)
+	defer cancel()
+
+	// Al editar suele reenviarse el evento entero: con otra dirección, las coordenadas guardadas ya no valen
+	staleCoordinates := previous != nil && previous.Address != location.Address &&
+		previous.Lat == location.Lat && previous.Lng == location.Lng && services.Geo != nil
+
+	if !location.HasCoordinates() || staleCoordinates {
+		switch {
+		case previous != nil && previous.Address == location.Address && previous.HasCoordinates():
+			location.Lat, location.Lng = previous.Lat, previous.Lng
+		case services.Geo == nil:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "location.lat and location.lng are required"})
+			return false
+		default:
+			place, err := services.Geo.Geocode(ctx, location.Address)
+			if errors.Is(err, services.ErrPlaceNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Address not found, send location.lat and location.lng instead"})
+				return false
+			}
+			if err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to geocode address", "details": err.Error()})
+				return false
+			}
+			location.Lat, location.Lng = place.Lat, place.Lng
+		}
+	}
+
+	if !services.ValidCoordinates(location.Lat, location.Lng) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "location.lat must be between -90 and 90 and location.lng between -180 and 180"})
+		return false
+	}
+
+	if previous != nil && previous.Lat == location.Lat && previous.Lng == location.Lng && previous.City != "" {
+		location.City, location.Province = previous.City, previous.Province
+		return true
+	}
+	// Sin geocodificador se guardan la ciudad y la provincia que haya enviado el organizador
+	if services.Geo == nil {
+		return true
+	}
+
+	place, err := services.Geo.Reverse(ctx, location.Lat, location.Lng)
+	if errors.Is(err, services.ErrPlaceNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The coordinates do not match any known place, check location.lat and location.lng"})
+		return false
+	}
+	if err != nil {
+		// El evento se guarda igual; solo queda sin ciudad para el filtro por ubicación
+		log.Printf("Error reverse geocoding %f,%f: %v", location.Lat, location.Lng, err)
+		return true
+	}
+	location.City, location.Province = place.City, place.Province
+
+	return true
+}
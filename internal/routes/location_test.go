@@ -0,0 +1,152 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// useFixtureGeocoder configura el geocodificador con los lugares incluidos mientras dura el test
+func useFixtureGeocoder(t *testing.T) {
+	t.Helper()
+	fixtures, err := services.LoadGeocoderFixtures("")
+	if err != nil {
+		t.Fatalf("LoadGeocoderFixtures: %v", err)
+	}
+	previous := services.Geo
+	services.Geo = fixtures
+	t.Cleanup(func() { services.Geo = previous })
+}
+
+// resolve corre la resolución de ubicación de createEvent (previous nil) o updateEvent y devuelve el status
+func resolve(t *testing.T, event *models.Event, previous *models.Location) (bool, int) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/events", nil)
+
+	ok := resolveEventLocation(c, event, previous)
+	return ok, recorder.Code
+}
+
+func TestCreateEventGeocodesAddress(t *testing.T) {
+	useFixtureGeocoder(t)
+
+	event := &models.Event{Location: models.Location{Address: " Monumento a la Bandera, Rosario "}}
+	if ok, status := resolve(t, event, nil); !ok {
+		t.Fatalf("resolve failed with status %d", status)
+	}
+
+	location := event.Location
+	if location.Lat != -32.9477 || location.Lng != -60.6302 {
+		t.Errorf("coordinates = %f,%f", location.Lat, location.Lng)
+	}
+	if location.City != "Rosario" || location.Province != "Santa Fe" {
+		t.Errorf("city = %q province = %q", location.City, location.Province)
+	}
+}
+
+func TestCreateEventWithCoordinatesFillsCity(t *testing.T) {
+	useFixtureGeocoder(t)
+
+	event := &models.Event{Location: models.Location{Address: "Dirección sin cargar", Lat: -31.43, Lng: -64.18, City: "Otra"}}
+	if ok, status := resolve(t, event, nil); !ok {
+		t.Fatalf("resolve failed with status %d", status)
+	}
+	if event.Location.City != "Córdoba" || event.Location.Lat != -31.43 {
+		t.Errorf("location = %+v", event.Location)
+	}
+}
+
+func TestCreateEventRejectsInvalidLocations(t *testing.T) {
+	useFixtureGeocoder(t)
+
+	tests := []struct {
+		name     string
+		location models.Location
+	}{
+		{"empty address", models.Location{Address: "  ", Lat: -34.6, Lng: -58.4}},
+		{"unknown address", models.Location{Address: "Calle Falsa 123"}},
+		{"coordinates out of range", models.Location{Address: "Plaza de Mayo, Buenos Aires", Lat: -91, Lng: -58.4}},
+		{"coordinates in the ocean", models.Location{Address: "Mar abierto", Lat: -45, Lng: -30}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &models.Event{Location: tt.location}
+			ok, status := resolve(t, event, nil)
+			if ok || status != http.StatusBadRequest {
+				t.Errorf("ok = %v status = %d, want %d", ok, status, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestCreateEventWithoutGeocoderRequiresCoordinates(t *testing.T) {
+	previous := services.Geo
+	services.Geo = nil
+	t.Cleanup(func() { services.Geo = previous })
+
+	event := &models.Event{Location: models.Location{Address: "Plaza de Mayo, Buenos Aires"}}
+	if ok, status := resolve(t, event, nil); ok || status != http.StatusBadRequest {
+		t.Errorf("ok = %v status = %d, want %d", ok, status, http.StatusBadRequest)
+	}
+
+	// Con coordenadas se guarda lo que mandó el organizador
+	event.Location = models.Location{Address: "Plaza de Mayo, Buenos Aires", Lat: -34.6, Lng: -58.37, City: "CABA"}
+	if ok, status := resolve(t, event, nil); !ok {
+		t.Fatalf("resolve failed with status %d", status)
+	}
+	if event.Location.City != "CABA" {
+		t.Errorf("city = %q, want the one sent", event.Location.City)
+	}
+}
+
+func TestUpdateEventKeepsUnchangedLocation(t *testing.T) {
+	useFixtureGeocoder(t)
+
+	previous := models.Location{Address: "Plaza de Mayo, Buenos Aires", Lat: -34.6, Lng: -58.37, City: "Guardada", Province: "Guardada"}
+	event := &models.Event{Location: models.Location{Address: previous.Address}}
+	if ok, status := resolve(t, event, &previous); !ok {
+		t.Fatalf("resolve failed with status %d", status)
+	}
+	if event.Location != previous {
+		t.Errorf("location = %+v, want %+v", event.Location, previous)
+	}
+}
+
+func TestUpdateEventRegeocodesChangedAddress(t *testing.T) {
+	useFixtureGeocoder(t)
+
+	previous := models.Location{Address: "Plaza de Mayo, Buenos Aires", Lat: -34.6083, Lng: -58.3712, City: "Buenos Aires", Province: "Ciudad Autónoma de Buenos Aires"}
+	// El cliente reenvía las coordenadas guardadas junto con la dirección nueva
+	event := &models.Event{Location: models.Location{Address: "Playa Bristol, Mar del Plata", Lat: previous.Lat, Lng: previous.Lng, City: previous.City}}
+	if ok, status := resolve(t, event, &previous); !ok {
+		t.Fatalf("resolve failed with status %d", status)
+	}
+
+	location := event.Location
+	if location.Lat != -38.0055 || location.Lng != -57.5426 {
+		t.Errorf("coordinates = %f,%f, want the new address", location.Lat, location.Lng)
+	}
+	if location.City != "Mar del Plata" || location.Province != "Buenos Aires" {
+		t.Errorf("city = %q province = %q", location.City, location.Province)
+	}
+}
+
+func TestUpdateEventWithMovedCoordinatesRefreshesCity(t *testing.T) {
+	useFixtureGeocoder(t)
+
+	previous := models.Location{Address: "Centro", Lat: -34.6083, Lng: -58.3712, City: "Buenos Aires", Province: "Ciudad Autónoma de Buenos Aires"}
+	event := &models.Event{Location: models.Location{Address: "Centro", Lat: -32.89, Lng: -68.87, City: previous.City}}
+	if ok, status := resolve(t, event, &previous); !ok {
+		t.Fatalf("resolve failed with status %d", status)
+	}
+	if event.Location.City != "Mendoza" || event.Location.Province != "Mendoza" {
+		t.Errorf("city = %q province = %q", event.Location.City, event.Location.Province)
+	}
+}
@@ -1,37 +1,124 @@
 package routes
 
 import (
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/mailer"
 	"github.com/AgusMolinaCode/restApi-Go.git/internal/middleware"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/oidc"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/whatsapp"
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterRoutes(router *gin.Engine) {
 	router.GET("/events", getEvents)
-	router.GET("/events/:id", getEventByID)
+	router.GET("/events/:id", middleware.OptionalAuth(), getEventByID)
 	router.GET("/tags", getAllTags)
 	router.GET("/events/by-tags", getEventsByTags)
 	router.GET("/events/by-category", getEventsByCategory)
 	router.GET("/events/by-date", getEventsByDate)
 	router.GET("/events/categories", getAllCategories)
 	router.GET("/events/by-name", getEventsByName)
+	router.GET("/events/by-location", getEventsByLocation)
 	router.GET("/events/summaries", getEventSummaries)
+	router.GET("/events/:id/availability", middleware.OptionalAuth(), getEventAvailability)
+	router.GET("/events/:id/availability/stream", middleware.OptionalAuth(), streamEventAvailability)
+	router.GET("/venues", getVenues)
+	router.GET("/venues/:id", getVenueByID)
+	router.GET("/venues/:id/events", getVenueEvents)
+	router.GET("/organizers/:id", middleware.GetOrganizer)
+	router.GET("/organizers/:id/events", middleware.GetOrganizerEvents)
 
 	protected := router.Group("/", middleware.AuthMiddleware())
 	{
-		protected.POST("/events", createEvent)
-		protected.PUT("/events/:id", updateEventByID)
-		protected.DELETE("/events/:id", deleteEventByID)
-		protected.POST("/events/:id/register", registerForEvent)
-		protected.DELETE("/events/:id/register", cancelRegistration)
-		protected.GET("/events/:id/registration", getRegistrationByEvent)
-		protected.PUT("/users/:id", middleware.UpdateUserByID)
-		protected.DELETE("/users/:id", middleware.DeleteUserByID)
+		protected.POST("/events", middleware.RequireScope("events:write"), createEvent)
+		protected.PUT("/events/:id", middleware.RequireScope("events:write"), updateEventByID)
+		protected.DELETE("/events/:id", middleware.RequireScope("events:write"), deleteEventByID)
+		protected.PUT("/events/:id/status", middleware.RequireScope("events:write"), updateEventStatus)
+		protected.GET("/users/me/events", middleware.RequireScope("events:read"), getMyEvents)
+		protected.POST("/events/:id/register", middleware.RequireScope("registrations:write"), registerForEvent)
+		protected.DELETE("/events/:id/register", middleware.RequireScope("registrations:write"), cancelRegistration)
+		protected.GET("/events/:id/registration", middleware.RequireScope("registrations:read"), getRegistrationByEvent)
+		protected.GET("/events/:id/weather-checks", middleware.RequireScope("events:read"), getEventWeatherChecks)
+		protected.POST("/venues", middleware.RequireScope("events:write"), createVenue)
+		protected.PUT("/venues/:id", middleware.RequireScope("events:write"), updateVenue)
+		protected.DELETE("/venues/:id", middleware.RequireScope("events:write"), deleteVenue)
+	}
+
+	// La gestión de la cuenta solo está disponible con una sesión de usuario
+	account := protected.Group("/", middleware.RequireSession())
+	{
+		account.GET("/users/me", middleware.GetMe)
+		account.PATCH("/users/me", middleware.PatchMe)
+		account.PUT("/users/me/privacy", middleware.UpdatePrivacySettings)
+		account.PUT("/users/me/notification-channels", middleware.UpdateNotificationChannels)
+		account.GET("/users/me/notification-preferences", middleware.GetNotificationPreferences)
+		account.PUT("/users/me/notification-preferences", middleware.UpdateNotificationPreferences)
+		account.GET("/users/me/notifications", middleware.GetMyNotifications)
+		account.GET("/users/me/notifications/stream", middleware.StreamNotifications)
+		account.POST("/users/me/notifications/read-all", middleware.MarkAllNotificationsRead)
+		account.POST("/users/me/notifications/:notificationId/read", middleware.MarkNotificationRead)
+		account.POST("/users/me/password", middleware.ChangePassword)
+		account.POST("/users/me/email", middleware.RequestEmailChange)
+		account.GET("/users/me/export", middleware.ExportMyData)
+		account.POST("/users/me/erasure", middleware.RequestErasure)
+		account.GET("/users/me/erasure", middleware.GetErasureStatus)
+		account.DELETE("/users/me/erasure", middleware.CancelErasure)
+		account.GET("/users/me/event-transfers", middleware.GetEventTransfers)
+		account.POST("/users/me/event-transfers/:userId/accept", middleware.AcceptEventTransfer)
+		account.POST("/users/me/event-transfers/:userId/decline", middleware.DeclineEventTransfer)
+		account.PUT("/users/:id", middleware.UpdateUserByID)
+		account.DELETE("/users/:id", middleware.DeleteUserByID)
+		account.POST("/users/me/2fa/setup", middleware.SetupTwoFactor)
+		account.POST("/users/me/2fa/confirm", middleware.ConfirmTwoFactor)
+		account.POST("/users/me/2fa/disable", middleware.DisableTwoFactor)
+		account.POST("/users/me/api-keys", middleware.CreateAPIKey)
+		account.GET("/users/me/api-keys", middleware.GetAPIKeys)
+		account.DELETE("/users/me/api-keys/:keyId", middleware.RevokeAPIKey)
+		account.POST("/users/me/webhooks", middleware.CreateWebhook)
+		account.GET("/users/me/webhooks", middleware.GetWebhooks)
+		account.GET("/users/me/webhooks/:webhookId", middleware.GetWebhook)
+		account.PATCH("/users/me/webhooks/:webhookId", middleware.UpdateWebhook)
+		account.DELETE("/users/me/webhooks/:webhookId", middleware.DeleteWebhook)
+		account.POST("/users/me/webhooks/:webhookId/test", middleware.TestWebhook)
+		account.GET("/users/me/webhooks/:webhookId/deliveries", middleware.GetWebhookDeliveries)
+		account.PUT("/users/me/organizer-profile", middleware.UpdateOrganizerProfile)
+		account.GET("/users/me/following", middleware.GetFollowing)
+		account.GET("/organizers/:id/follow", middleware.GetFollowStatus)
+		account.POST("/organizers/:id/follow", middleware.FollowOrganizer)
+		account.DELETE("/organizers/:id/follow", middleware.UnfollowOrganizer)
+	}
+
+	admin := account.Group("/", middleware.RequireAdmin())
+	{
+		admin.GET("/users", middleware.GetAllUsers)
+		admin.GET("/admin/jobs", middleware.GetJobs)
+		admin.GET("/admin/jobs/:id", middleware.GetJob)
+		admin.POST("/admin/jobs/:id/retry", middleware.RetryJob)
+		admin.PUT("/admin/organizers/:id/verification", middleware.SetOrganizerVerification)
 	}
 
 	router.POST("/signup", middleware.Signup)
 	router.POST("/login", middleware.Login)
+	router.POST("/login/2fa", middleware.LoginTwoFactor)
 	router.POST("/forgot-password", middleware.ForgotPassword)
 	router.POST("/reset-password", middleware.ResetPassword)
+	router.POST("/confirm-email", middleware.ConfirmEmailChange)
+	router.GET("/unsubscribe", middleware.GetUnsubscribe)
+	router.POST("/unsubscribe", middleware.Unsubscribe)
 	router.GET("/users/:id", middleware.GetUserByID)
-	router.GET("/users", middleware.GetAllUsers)
+	router.GET("/.well-known/jwks.json", middleware.JWKS)
+	router.GET("/auth/oidc/login", middleware.OIDCLogin)
+	router.GET("/auth/oidc/callback", middleware.OIDCCallback)
+
+	// Buzones locales para desarrollo (MAIL_DRIVER=memory, WHATSAPP_PROVIDER=fake)
+	if mailer.Mailbox != nil {
+		router.Any("/dev/mailbox", gin.WrapH(mailer.Mailbox))
+	}
+	if whatsapp.Outbox != nil {
+		router.Any("/dev/whatsapp", gin.WrapH(whatsapp.Outbox))
+	}
+
+	// Proveedor OIDC local para desarrollo (OIDC_STUB=true)
+	if oidc.Stub != nil {
+		router.Any("/dev/oidc/*path", gin.WrapH(oidc.Stub))
+	}
 }
@@ -0,0 +1,272 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/middleware"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/notifications"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/webhooks"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// resolveEventLocation usa el lugar indicado en venue_id, que tiene que ser del organizador, o si no
+// hay, la ubicación cargada a mano
+func resolveEventLocation(c *gin.Context, event *models.Event, previous *models.Location) bool {
+	if event.VenueID == "" {
+		return resolveLocation(c, &event.Location, previous)
+	}
+
+	venue, err := models.GetVenueByID(event.VenueID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve venue", "details": err.Error()})
+		return false
+	}
+	if venue == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Venue not found"})
+		return false
+	}
+	// Los cambios del lugar se copian a sus eventos: solo su dueño puede usarlo
+	if venue.UserID != c.GetString("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only use your own venues"})
+		return false
+	}
+
+	if venue.Capacity > 0 {
+		for date, dateTime := range event.DateTimes {
+			if dateTime.Capacity > venue.Capacity {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "capacity cannot exceed the venue capacity", "date": date, "venue_capacity": venue.Capacity})
+				return false
+			}
+		}
+	}
+
+	venue.ApplyTo(event)
+	return true
+}
+
+func bindVenue(c *gin.Context, venue *models.Venue) bool {
+	if err := c.ShouldBindJSON(venue); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	venue.Name = strings.TrimSpace(venue.Name)
+	if venue.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return false
+	}
+	if venue.Capacity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "capacity cannot be negative"})
+		return false
+	}
+	if venue.Accessibility == nil {
+		venue.Accessibility = []string{}
+	}
+	if venue.Photos == nil {
+		venue.Photos = []string{}
+	}
+	return true
+}
+
+// ownVenue carga el lugar y comprueba que lo haya cargado el usuario autenticado
+func ownVenue(c *gin.Context) (*models.Venue, bool) {
+	venue, err := models.GetVenueByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve venue", "details": err.Error()})
+		return nil, false
+	}
+	if venue == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Venue not found"})
+		return nil, false
+	}
+	if venue.UserID != c.GetString("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to modify this venue"})
+		return nil, false
+	}
+	return venue, true
+}
+
+func createVenue(c *gin.Context) {
+	var venue models.Venue
+	if !bindVenue(c, &venue) {
+		return
+	}
+	if !resolveLocation(c, &venue.Location, nil) {
+		return
+	}
+
+	venue.ID = uuid.New().String()
+	venue.UserID = c.GetString("userID")
+
+	if err := venue.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create venue", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Venue created successfully", "venue": venue})
+}
+
+func getVenues(c *gin.Context) {
+	page, limit := middleware.PaginationParams(c, 20, 100)
+	venues, total, err := models.GetVenues(strings.TrimSpace(c.Query("city")), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve venues", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"venues": venues, "page": page, "limit": limit, "total": total})
+}
+
+func getVenueByID(c *gin.Context) {
+	venue, err := models.GetVenueByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve venue", "details": err.Error()})
+		return
+	}
+	if venue == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Venue not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, venue)
+}
+
+func getVenueEvents(c *gin.Context) {
+	venue, err := models.GetVenueByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve venue", "details": err.Error()})
+		return
+	}
+	if venue == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Venue not found"})
+		return
+	}
+
+	events, err := models.GetEventSummariesByVenueID(venue.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"venue": venue, "events": events})
+}
+
+// venueCapacityError indica que una fecha de un evento que usa el lugar tiene más cupo que la capacidad nueva
+type venueCapacityError struct {
+	EventID  string
+	Date     string
+	Capacity int
+}
+
+func (e *venueCapacityError) Error() string {
+	return fmt.Sprintf("event %s has capacity %d on %s", e.EventID, e.Capacity, e.Date)
+}
+
+// updateVenue también actualiza la copia de los eventos del dueño que usan el lugar y avisa a sus
+// inscriptos si cambió la dirección. Si baja la capacidad, ninguna fecha puede quedar por encima.
+func updateVenue(c *gin.Context) {
+	venue, ok := ownVenue(c)
+	if !ok {
+		return
+	}
+
+	var updated models.Venue
+	if !bindVenue(c, &updated) {
+		return
+	}
+	if !resolveLocation(c, &updated.Location, &venue.Location) {
+		return
+	}
+	updated.ID = venue.ID
+	updated.UserID = venue.UserID
+	updated.CreatedAt = venue.CreatedAt
+
+	updatedEvents := 0
+	err := database.WithTx(func(tx *sql.Tx) error {
+		if err := models.UpdateVenue(tx, &updated); err != nil {
+			return err
+		}
+
+		eventIDs, err := models.GetEventIDsByVenueID(tx, venue.ID)
+		if err != nil {
+			return err
+		}
+		for _, eventID := range eventIDs {
+			before, err := models.LockEventByID(tx, eventID)
+			if err != nil {
+				return err
+			}
+			// Los cancelados y archivados conservan el lugar tal como estaba, y los de otros
+			// organizadores (cargados antes de exigir que el lugar sea propio) no se tocan
+			if before == nil || before.UserID != venue.UserID || before.Status == models.EventStatusCancelled || before.Status == models.EventStatusArchived {
+				continue
+			}
+			if updated.Capacity > 0 {
+				for date, dateTime := range before.DateTimes {
+					if dateTime.Capacity > updated.Capacity {
+						return &venueCapacityError{EventID: eventID, Date: date, Capacity: dateTime.Capacity}
+					}
+				}
+			}
+
+			after := *before
+			updated.ApplyTo(&after)
+			after.UpdatedAt = time.Now().Format(time.RFC3339)
+			if err := models.UpdateEventByID(tx, eventID, after); err != nil {
+				return err
+			}
+			if err := notifications.EventChanged(tx, before, &after); err != nil {
+				return err
+			}
+			if err := webhooks.Dispatch(tx, before.UserID, webhooks.EventEventUpdated, gin.H{"event": after}); err != nil {
+				return err
+			}
+			updatedEvents++
+		}
+		return nil
+	})
+	var capacityErr *venueCapacityError
+	if errors.As(err, &capacityErr) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An event using the venue has a date with more capacity than the new venue capacity", "event_id": capacityErr.EventID, "date": capacityErr.Date, "capacity": capacityErr.Capacity})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update venue", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Venue updated successfully", "venue": updated, "updated_events": updatedEvents})
+}
+
+// deleteVenue solo borra lugares que ningún evento usa, ni siquiera archivado
+func deleteVenue(c *gin.Context) {
+	venue, ok := ownVenue(c)
+	if !ok {
+		return
+	}
+
+	count, err := models.CountEventsByVenueID(venue.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check venue events", "details": err.Error()})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "The venue is used by events", "events": count})
+		return
+	}
+
+	if err := models.DeleteVenue(venue.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete venue", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Venue deleted successfully"})
+}
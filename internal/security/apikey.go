@@ -0,0 +1,61 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const apiKeyPrefix = "rak"
+
+// Scopes que se pueden conceder a una API key
+var APIKeyScopes = []string{
+	"events:read",
+	"events:write",
+	"registrations:read",
+	"registrations:write",
+}
+
+// GenerateAPIKey devuelve la clave completa (solo se muestra una vez), un prefijo visible
+// para identificarla y el hash que se guarda en la base de datos
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	publicPart := make([]byte, 4)
+	if _, err := rand.Read(publicPart); err != nil {
+		return "", "", "", err
+	}
+	secretPart := make([]byte, 32)
+	if _, err := rand.Read(secretPart); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = fmt.Sprintf("%s_%s", apiKeyPrefix, hex.EncodeToString(publicPart))
+	key = fmt.Sprintf("%s_%s", prefix, base64.RawURLEncoding.EncodeToString(secretPart))
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// HashAPIKey usa SHA-256: las claves tienen 256 bits de entropía, así que no hace falta bcrypt
+// y el hash permite buscarlas directamente
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !isKnownScope(scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+func isKnownScope(scope string) bool {
+	for _, known := range APIKeyScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package security
+
+import (
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+type AttemptRecord struct {
+	Count       int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// AttemptStore guarda los contadores de intentos fallidos; Increment debe ser atómico
+type AttemptStore interface {
+	Get(key string) (AttemptRecord, error)
+	Increment(key string, window time.Duration, now time.Time) (AttemptRecord, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// MemoryAttemptStore mantiene los contadores en memoria (una sola réplica o desarrollo local)
+type MemoryAttemptStore struct {
+	mu      sync.Mutex
+	records map[string]AttemptRecord
+}
+
+const memoryStoreSweepSize = 10000
+
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{records: make(map[string]AttemptRecord)}
+}
+
+func (s *MemoryAttemptStore) Get(key string) (AttemptRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[key], nil
+}
+
+func (s *MemoryAttemptStore) Increment(key string, window time.Duration, now time.Time) (AttemptRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.records) > memoryStoreSweepSize {
+		s.sweep(window, now)
+	}
+
+	// Los fallos se olvidan tras una ventana completa sin intentos nuevos
+	record := s.records[key]
+	if now.Sub(record.LastFailure) > window {
+		record.Count = 0
+	}
+	record.Count++
+	record.LastFailure = now
+	s.records[key] = record
+
+	return record, nil
+}
+
+func (s *MemoryAttemptStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.records[key]
+	record.LockedUntil = until
+	s.records[key] = record
+	return nil
+}
+
+func (s *MemoryAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// sweep elimina los contadores vencidos para que el mapa no crezca indefinidamente
+func (s *MemoryAttemptStore) sweep(window time.Duration, now time.Time) {
+	for key, record := range s.records {
+		if now.Sub(record.LastFailure) > window && now.After(record.LockedUntil) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// DBAttemptStore comparte los contadores entre réplicas usando la tabla login_attempts
+type DBAttemptStore struct{}
+
+func (DBAttemptStore) Get(key string) (AttemptRecord, error) {
+	query := `SELECT count, last_failure, locked_until FROM login_attempts WHERE key = $1`
+	row := database.DB.QueryRow(query, key)
+
+	var record AttemptRecord
+	var lockedUntil sql.NullTime
+	err := row.Scan(&record.Count, &record.LastFailure, &lockedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return AttemptRecord{}, nil
+		}
+		return AttemptRecord{}, err
+	}
+	record.LockedUntil = lockedUntil.Time
+
+	return record, nil
+}
+
+func (DBAttemptStore) Increment(key string, window time.Duration, now time.Time) (AttemptRecord, error) {
+	// Un único upsert mantiene el contador consistente con peticiones concurrentes
+	query := `
+		INSERT INTO login_attempts (key, count, last_failure)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			count = CASE WHEN login_attempts.last_failure < $3 THEN 1 ELSE login_attempts.count + 1 END,
+			last_failure = $2
+		RETURNING count, last_failure, locked_until
+	`
+	row := database.DB.QueryRow(query, key, now, now.Add(-window))
+
+	var record AttemptRecord
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&record.Count, &record.LastFailure, &lockedUntil); err != nil {
+		return AttemptRecord{}, err
+	}
+	record.LockedUntil = lockedUntil.Time
+
+	return record, nil
+}
+
+func (DBAttemptStore) Lock(key string, until time.Time) error {
+	query := `UPDATE login_attempts SET locked_until = $1 WHERE key = $2`
+	_, err := database.DB.Exec(query, until, key)
+	return err
+}
+
+func (DBAttemptStore) Reset(key string) error {
+	query := `DELETE FROM login_attempts WHERE key = $1`
+	_, err := database.DB.Exec(query, key)
+	return err
+}
+
+// DeleteExpired borra los contadores sin fallos durante una ventana completa y sin bloqueo vigente,
+// igual que sweep en memoria; si no, cada email inventado o IP probada quedaría para siempre
+func (DBAttemptStore) DeleteExpired(window time.Duration, now time.Time) (int64, error) {
+	query := `DELETE FROM login_attempts WHERE last_failure < $1 AND (locked_until IS NULL OR locked_until < $2)`
+	result, err := database.DB.Exec(query, now.Add(-window), now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s DBAttemptStore) cleanup(window time.Duration) {
+	for {
+		if deleted, err := s.DeleteExpired(window, time.Now()); err != nil {
+			log.Printf("Failed to clean up login attempts: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d expired login attempt counters", deleted)
+		}
+		time.Sleep(time.Hour)
+	}
+}
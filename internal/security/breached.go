@@ -0,0 +1,89 @@
+package security
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// BreachRangeSource sigue el modelo k-anonymity de Have I Been Pwned: recibe solo los
+// 5 primeros caracteres del SHA-1 y devuelve los sufijos conocidos con sus apariciones
+type BreachRangeSource interface {
+	Range(prefix string) (map[string]int, error)
+}
+
+// BreachCount devuelve cuántas veces aparece la contraseña en filtraciones conocidas
+func BreachCount(source BreachRangeSource, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := source.Range(hash[:5])
+	if err != nil {
+		return 0, err
+	}
+	return suffixes[hash[5:]], nil
+}
+
+// BreachedHashList es una lista de hashes cargada en memoria, agrupada por prefijo
+type BreachedHashList struct {
+	ranges map[string]map[string]int
+	size   int
+}
+
+// LoadBreachedHashFile lee un archivo con una línea "SHA1" o "SHA1:APARICIONES" por hash,
+// el mismo formato que las descargas de Have I Been Pwned
+func LoadBreachedHashFile(path string) (*BreachedHashList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &BreachedHashList{ranges: make(map[string]map[string]int)}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		hash, countText, hasCount := strings.Cut(text, ":")
+		hash = strings.ToUpper(hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 40 {
+			return nil, fmt.Errorf("line %d: invalid SHA-1 hash", line)
+		}
+
+		count := 1
+		if hasCount {
+			if count, err = strconv.Atoi(countText); err != nil {
+				return nil, fmt.Errorf("line %d: invalid count", line)
+			}
+		}
+
+		prefix := hash[:5]
+		if list.ranges[prefix] == nil {
+			list.ranges[prefix] = make(map[string]int)
+		}
+		if _, exists := list.ranges[prefix][hash[5:]]; !exists {
+			list.size++
+		}
+		list.ranges[prefix][hash[5:]] = count
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (l *BreachedHashList) Range(prefix string) (map[string]int, error) {
+	return l.ranges[strings.ToUpper(prefix)], nil
+}
+
+func (l *BreachedHashList) Len() int {
+	return l.size
+}
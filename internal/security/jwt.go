@@ -0,0 +1,299 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type signingKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet firma con una única clave activa y verifica con todas las claves publicadas,
+// lo que permite rotar claves sin invalidar los tokens ya emitidos
+type KeySet struct {
+	active   *signingKey
+	verify   map[string]*signingKey
+	order    []string
+	issuer   string
+	audience string
+}
+
+var Keys *KeySet
+
+var ErrInvalidToken = errors.New("invalid token")
+
+func InitKeys() {
+	keys, err := loadKeySet()
+	if err != nil {
+		log.Fatalf("Error loading JWT keys: %v", err)
+	}
+	Keys = keys
+}
+
+func loadKeySet() (*KeySet, error) {
+	keys := &KeySet{
+		verify:   make(map[string]*signingKey),
+		issuer:   envString("JWT_ISSUER", "restapi-go"),
+		audience: envString("JWT_AUDIENCE", "restapi-go"),
+	}
+
+	privatePEM, err := envOrFile("JWT_PRIVATE_KEY", "JWT_PRIVATE_KEY_FILE")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(privatePEM) == 0 {
+		// Sin clave configurada solo se permite una clave efímera fuera de producción
+		if os.Getenv("GIN_MODE") == "release" {
+			return nil, errors.New("JWT_PRIVATE_KEY or JWT_PRIVATE_KEY_FILE is required in release mode")
+		}
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("No JWT signing key configured, using an ephemeral Ed25519 key (tokens will not survive restarts)")
+		privatePEM, err = encodePrivateKey(private)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	active, err := parsePrivateKey(privatePEM)
+	if err != nil {
+		return nil, err
+	}
+	keys.active = active
+	keys.add(active)
+
+	// Claves públicas anteriores que siguen siendo válidas para verificar durante la rotación
+	verifyPEM, err := envOrFile("JWT_VERIFY_KEYS", "JWT_VERIFY_KEYS_FILE")
+	if err != nil {
+		return nil, err
+	}
+	for len(verifyPEM) > 0 {
+		var block *pem.Block
+		block, verifyPEM = pem.Decode(verifyPEM)
+		if block == nil {
+			break
+		}
+		key, err := parsePublicKey(block)
+		if err != nil {
+			return nil, err
+		}
+		keys.add(key)
+	}
+
+	return keys, nil
+}
+
+func (k *KeySet) add(key *signingKey) {
+	if _, exists := k.verify[key.ID]; exists {
+		return
+	}
+	k.verify[key.ID] = key
+	k.order = append(k.order, key.ID)
+}
+
+// Issue firma los claims con la clave activa añadiendo iss, aud, iat y exp
+func (k *KeySet) Issue(claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims["iss"] = k.issuer
+	claims["aud"] = k.audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+
+	token := jwt.NewWithClaims(k.active.Method, claims)
+	token.Header["kid"] = k.active.ID
+
+	return token.SignedString(k.active.Private)
+}
+
+// Parse verifica la firma con la clave indicada por kid y exige alg, exp, iss y aud válidos
+func (k *KeySet) Parse(tokenString string) (jwt.MapClaims, error) {
+	methods := make([]string, 0, len(k.verify))
+	for _, key := range k.verify {
+		methods = append(methods, key.Method.Alg())
+	}
+
+	parser := jwt.NewParser(jwt.WithValidMethods(methods))
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := k.verify[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		// El algoritmo del token debe coincidir con el de la clave, no solo estar permitido
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.Public, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now().Unix()
+	if !claims.VerifyExpiresAt(now, true) || !claims.VerifyIssuer(k.issuer, true) || !claims.VerifyAudience(k.audience, true) {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS devuelve las claves públicas de verificación en formato RFC 7517
+func (k *KeySet) JWKS() []JWK {
+	jwks := make([]JWK, 0, len(k.order))
+	for _, kid := range k.order {
+		key := k.verify[kid]
+		jwk := JWK{Kid: key.ID, Alg: key.Method.Alg(), Use: "sig"}
+
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
+
+func parsePrivateKey(data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("JWT private key is not valid PEM")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported JWT private key type")
+	}
+
+	return newSigningKey(signer.Public(), signer)
+}
+
+func parsePublicKey(block *pem.Block) (*signingKey, error) {
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newSigningKey(parsed, nil)
+}
+
+func newSigningKey(public crypto.PublicKey, private crypto.Signer) (*signingKey, error) {
+	var method jwt.SigningMethod
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return nil, errors.New("RSA JWT keys must be at least 2048 bits")
+		}
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported JWT key type %T", public)
+	}
+
+	kid, err := keyID(public)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signingKey{ID: kid, Method: method, Private: private, Public: public}, nil
+}
+
+// keyID deriva un kid estable a partir de la clave pública
+func keyID(public crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+func encodePrivateKey(private crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func envOrFile(valueName, fileName string) ([]byte, error) {
+	if value := os.Getenv(valueName); value != "" {
+		return []byte(value), nil
+	}
+	if path := os.Getenv(fileName); path != "" {
+		return os.ReadFile(path)
+	}
+	return nil, nil
+}
+
+func envString(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
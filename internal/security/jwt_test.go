@@ -0,0 +1,219 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// testKeys son las claves de los tests: se generan una sola vez porque RSA es lento
+var testKeys = struct {
+	rsa     *rsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}{}
+
+func init() {
+	var err error
+	if testKeys.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		panic(err)
+	}
+	if _, testKeys.ed25519, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		panic(err)
+	}
+}
+
+func publicKeyPEM(t *testing.T, public crypto.PublicKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// newKeySet carga un KeySet como en producción: clave activa y claves de verificación por variables de entorno
+func newKeySet(t *testing.T, active crypto.Signer, verify ...crypto.PublicKey) *KeySet {
+	t.Helper()
+	privatePEM, err := encodePrivateKey(active)
+	if err != nil {
+		t.Fatalf("encodePrivateKey: %v", err)
+	}
+	var verifyPEM []byte
+	for _, public := range verify {
+		verifyPEM = append(verifyPEM, publicKeyPEM(t, public)...)
+	}
+
+	t.Setenv("JWT_PRIVATE_KEY", string(privatePEM))
+	t.Setenv("JWT_VERIFY_KEYS", string(verifyPEM))
+	t.Setenv("JWT_ISSUER", "test-issuer")
+	t.Setenv("JWT_AUDIENCE", "test-audience")
+
+	keys, err := loadKeySet()
+	if err != nil {
+		t.Fatalf("loadKeySet: %v", err)
+	}
+	return keys
+}
+
+func kidOf(t *testing.T, public crypto.PublicKey) string {
+	t.Helper()
+	kid, err := keyID(public)
+	if err != nil {
+		t.Fatalf("keyID: %v", err)
+	}
+	return kid
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"user_id": "user-1",
+		"iss":     "test-issuer",
+		"aud":     "test-audience",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims, key interface{}) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+func TestParseAcceptsIssuedToken(t *testing.T) {
+	for name, active := range map[string]crypto.Signer{"RS256": testKeys.rsa, "EdDSA": testKeys.ed25519} {
+		t.Run(name, func(t *testing.T) {
+			keys := newKeySet(t, active)
+			token, err := keys.Issue(jwt.MapClaims{"user_id": "user-1"}, time.Hour)
+			if err != nil {
+				t.Fatalf("Issue: %v", err)
+			}
+			claims, err := keys.Parse(token)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if claims["user_id"] != "user-1" {
+				t.Errorf("user_id = %v", claims["user_id"])
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidTokens(t *testing.T) {
+	// La clave activa es RSA; la Ed25519 solo verifica, como durante una rotación
+	keys := newKeySet(t, testKeys.rsa, testKeys.ed25519.Public())
+	rsaKid := kidOf(t, &testKeys.rsa.PublicKey)
+	edKid := kidOf(t, testKeys.ed25519.Public())
+	otherRSA, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	noExp := validClaims()
+	delete(noExp, "exp")
+	wrongIssuer := validClaims()
+	wrongIssuer["iss"] = "other-issuer"
+	wrongAudience := validClaims()
+	wrongAudience["aud"] = "other-audience"
+	noAudience := validClaims()
+	delete(noAudience, "aud")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"alg none", sign(t, jwt.SigningMethodNone, rsaKid, validClaims(), jwt.UnsafeAllowNoneSignatureType)},
+		// Confusión de algoritmos: HMAC usando la clave pública (conocida por todos) como secreto
+		{"HS256 with the RSA public key as secret", sign(t, jwt.SigningMethodHS256, rsaKid, validClaims(), publicKeyPEM(t, &testKeys.rsa.PublicKey))},
+		{"HS256 with the Ed25519 public key as secret", sign(t, jwt.SigningMethodHS256, edKid, validClaims(), []byte(testKeys.ed25519.Public().(ed25519.PublicKey)))},
+		// Algoritmo permitido por otra clave del conjunto, pero no por la del kid
+		{"EdDSA with the RSA kid", sign(t, jwt.SigningMethodEdDSA, rsaKid, validClaims(), testKeys.ed25519)},
+		{"RS256 with the Ed25519 kid", sign(t, jwt.SigningMethodRS256, edKid, validClaims(), testKeys.rsa)},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, "unknown", validClaims(), testKeys.rsa)},
+		{"missing kid", sign(t, jwt.SigningMethodRS256, "", validClaims(), testKeys.rsa)},
+		{"signed by another key with a known kid", sign(t, jwt.SigningMethodRS256, rsaKid, validClaims(), otherRSA)},
+		{"expired", sign(t, jwt.SigningMethodRS256, rsaKid, expired, testKeys.rsa)},
+		{"missing exp", sign(t, jwt.SigningMethodRS256, rsaKid, noExp, testKeys.rsa)},
+		{"wrong issuer", sign(t, jwt.SigningMethodRS256, rsaKid, wrongIssuer, testKeys.rsa)},
+		{"wrong audience", sign(t, jwt.SigningMethodRS256, rsaKid, wrongAudience, testKeys.rsa)},
+		{"missing audience", sign(t, jwt.SigningMethodRS256, rsaKid, noAudience, testKeys.rsa)},
+		{"malformed", "not.a.token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := keys.Parse(tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Parse err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+
+	// Control: el mismo armado con datos correctos sí se acepta
+	if _, err := keys.Parse(sign(t, jwt.SigningMethodRS256, rsaKid, validClaims(), testKeys.rsa)); err != nil {
+		t.Errorf("valid token rejected: %v", err)
+	}
+	if _, err := keys.Parse(sign(t, jwt.SigningMethodEdDSA, edKid, validClaims(), testKeys.ed25519)); err != nil {
+		t.Errorf("valid token signed by the verification key rejected: %v", err)
+	}
+}
+
+func TestParseRejectsTamperedPayload(t *testing.T) {
+	keys := newKeySet(t, testKeys.ed25519)
+	token, err := keys.Issue(jwt.MapClaims{"user_id": "user-1"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	forged := sign(t, jwt.SigningMethodEdDSA, kidOf(t, testKeys.ed25519.Public()), jwt.MapClaims{"user_id": "admin"}, testKeys.ed25519)
+	parts[1] = strings.Split(forged, ".")[1]
+	if _, err := keys.Parse(strings.Join(parts, ".")); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Parse err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestParseAfterKeyRotation(t *testing.T) {
+	before := newKeySet(t, testKeys.rsa)
+	token, err := before.Issue(jwt.MapClaims{"user_id": "user-1"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	// La clave nueva firma y la anterior queda publicada para verificar los tokens ya emitidos
+	rotated := newKeySet(t, testKeys.ed25519, &testKeys.rsa.PublicKey)
+	if _, err := rotated.Parse(token); err != nil {
+		t.Errorf("token from the previous key rejected after rotation: %v", err)
+	}
+	newToken, err := rotated.Issue(jwt.MapClaims{"user_id": "user-1"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, err := rotated.Parse(newToken); err != nil {
+		t.Errorf("token from the new key rejected: %v", err)
+	}
+
+	// Al retirar la clave anterior sus tokens dejan de valer
+	retired := newKeySet(t, testKeys.ed25519)
+	if _, err := retired.Parse(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Parse err = %v, want ErrInvalidToken once the old key is retired", err)
+	}
+	if len(rotated.JWKS()) != 2 || len(retired.JWKS()) != 1 {
+		t.Errorf("JWKS sizes = %d and %d, want 2 and 1", len(rotated.JWKS()), len(retired.JWKS()))
+	}
+}
@@ -0,0 +1,173 @@
+package security
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type GuardConfig struct {
+	MaxAccountAttempts int
+	MaxIPAttempts      int
+	AttemptWindow      time.Duration
+	BaseLockout        time.Duration
+	MaxLockout         time.Duration
+	MaxResetRequests   int
+	ResetWindow        time.Duration
+}
+
+// LoginGuard aplica backoff exponencial y bloqueo temporal por cuenta y por IP
+type LoginGuard struct {
+	store  AttemptStore
+	config GuardConfig
+}
+
+var Guard *LoginGuard
+
+func InitLoginGuard() {
+	config := GuardConfig{
+		MaxAccountAttempts: envInt("LOGIN_MAX_ATTEMPTS", 5),
+		MaxIPAttempts:      envInt("LOGIN_MAX_IP_ATTEMPTS", 20),
+		AttemptWindow:      envDuration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute),
+		BaseLockout:        envDuration("LOGIN_LOCKOUT_BASE", time.Minute),
+		MaxLockout:         envDuration("LOGIN_LOCKOUT_MAX", time.Hour),
+		MaxResetRequests:   envInt("FORGOT_PASSWORD_MAX_REQUESTS", 3),
+		ResetWindow:        envDuration("FORGOT_PASSWORD_WINDOW", time.Hour),
+	}
+
+	var store AttemptStore
+	switch os.Getenv("ATTEMPT_STORE") {
+	case "database":
+		store = DBAttemptStore{}
+		// Los contadores de los inicios de sesión y de los pedidos de recuperación vencen con ventanas distintas
+		go DBAttemptStore{}.cleanup(max(config.AttemptWindow, config.ResetWindow))
+	case "", "memory":
+		store = NewMemoryAttemptStore()
+	default:
+		log.Fatalf("Unknown ATTEMPT_STORE %q (use memory or database)", os.Getenv("ATTEMPT_STORE"))
+	}
+
+	Guard = NewLoginGuard(store, config)
+}
+
+func NewLoginGuard(store AttemptStore, config GuardConfig) *LoginGuard {
+	return &LoginGuard{store: store, config: config}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func resetKey(email string) string {
+	return "forgot:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// Check devuelve cuánto falta para poder volver a intentar; cero si no hay bloqueo
+func (g *LoginGuard) Check(email, ip string) (time.Duration, error) {
+	now := time.Now()
+	var retryAfter time.Duration
+
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		record, err := g.store.Get(key)
+		if err != nil {
+			return 0, err
+		}
+		if wait := record.LockedUntil.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	return retryAfter, nil
+}
+
+// RegisterFailure suma un intento fallido y bloquea la cuenta o la IP al superar el umbral.
+// accountLocked es true solo en el intento que inicia un nuevo bloqueo de la cuenta.
+func (g *LoginGuard) RegisterFailure(email, ip string) (accountLocked bool, retryAfter time.Duration, err error) {
+	now := time.Now()
+
+	accountLocked, accountWait, err := g.fail(accountKey(email), g.config.MaxAccountAttempts, now)
+	if err != nil {
+		return false, 0, err
+	}
+
+	_, ipWait, err := g.fail(ipKey(ip), g.config.MaxIPAttempts, now)
+	if err != nil {
+		return false, 0, err
+	}
+
+	if ipWait > accountWait {
+		return accountLocked, ipWait, nil
+	}
+	return accountLocked, accountWait, nil
+}
+
+func (g *LoginGuard) fail(key string, threshold int, now time.Time) (bool, time.Duration, error) {
+	record, err := g.store.Increment(key, g.config.AttemptWindow, now)
+	if err != nil {
+		return false, 0, err
+	}
+	if record.Count < threshold {
+		return false, 0, nil
+	}
+
+	// Cada fallo por encima del umbral duplica la duración del bloqueo
+	lockout := g.config.BaseLockout
+	for i := threshold; i < record.Count && lockout < g.config.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > g.config.MaxLockout {
+		lockout = g.config.MaxLockout
+	}
+
+	wasLocked := record.LockedUntil.After(now)
+	if err := g.store.Lock(key, now.Add(lockout)); err != nil {
+		return false, 0, err
+	}
+
+	return !wasLocked, lockout, nil
+}
+
+func (g *LoginGuard) RegisterSuccess(email string) error {
+	return g.store.Reset(accountKey(email))
+}
+
+// AllowPasswordReset limita cuántos emails de recuperación se envían a una misma dirección
+func (g *LoginGuard) AllowPasswordReset(email string) (bool, error) {
+	record, err := g.store.Increment(resetKey(email), g.config.ResetWindow, time.Now())
+	if err != nil {
+		return false, err
+	}
+	return record.Count <= g.config.MaxResetRequests, nil
+}
+
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %d", name, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %s", name, value, fallback)
+		return fallback
+	}
+	return parsed
+}
@@ -0,0 +1,97 @@
+package security
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// bcrypt ignora todo lo que pase de 72 bytes
+const maxPasswordBytes = 72
+
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type PasswordPolicy struct {
+	MinLength int
+	MinScore  int
+	Breached  BreachRangeSource
+}
+
+var Passwords *PasswordPolicy
+
+func InitPasswordPolicy() {
+	policy := &PasswordPolicy{
+		MinLength: envInt("PASSWORD_MIN_LENGTH", 10),
+		MinScore:  envInt("PASSWORD_MIN_SCORE", 3),
+	}
+
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		list, err := LoadBreachedHashFile(path)
+		if err != nil {
+			log.Fatalf("Error loading BREACHED_PASSWORDS_FILE: %v", err)
+		}
+		policy.Breached = list
+		log.Printf("Loaded %d breached password hashes", list.Len())
+	}
+
+	Passwords = policy
+}
+
+// Validate devuelve todas las reglas que incumple la contraseña; personalInfo son datos
+// del usuario (email, nombre de usuario) que no se pueden usar como contraseña
+func (p *PasswordPolicy) Validate(password string, personalInfo ...string) []PasswordViolation {
+	violations := []PasswordViolation{}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PasswordViolation{"too_short", fmt.Sprintf("Password must be at least %d characters long", p.MinLength)})
+	}
+	if len(password) > maxPasswordBytes {
+		violations = append(violations, PasswordViolation{"too_long", fmt.Sprintf("Password must be at most %d bytes long", maxPasswordBytes)})
+	}
+
+	inputs := personalInputs(personalInfo)
+	lower := strings.ToLower(password)
+	for _, input := range inputs {
+		if lower == input {
+			violations = append(violations, PasswordViolation{"personal_info", "Password must not be your email or username"})
+			break
+		}
+	}
+
+	if score := EstimateStrength(password, inputs...); score < p.MinScore {
+		violations = append(violations, PasswordViolation{"too_weak", fmt.Sprintf("Password is too easy to guess (strength %d of 4, minimum %d)", score, p.MinScore)})
+	}
+
+	if p.Breached != nil {
+		count, err := BreachCount(p.Breached, password)
+		if err != nil {
+			// Si la lista no está disponible no se bloquea el registro
+			log.Printf("Breached password check failed: %v", err)
+		} else if count > 0 {
+			violations = append(violations, PasswordViolation{"breached", "Password has appeared in a known data breach"})
+		}
+	}
+
+	return violations
+}
+
+// personalInputs normaliza los datos del usuario e incluye la parte local del email
+func personalInputs(values []string) []string {
+	var inputs []string
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		inputs = append(inputs, value)
+		if at := strings.Index(value, "@"); at > 0 {
+			inputs = append(inputs, value[:at])
+		}
+	}
+	return inputs
+}
@@ -0,0 +1,171 @@
+package security
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Contraseñas y palabras base muy frecuentes, ordenadas por popularidad
+var commonPasswordWords = []string{
+	"password", "123456", "qwerty", "admin", "welcome", "letmein", "iloveyou", "monkey",
+	"dragon", "football", "baseball", "sunshine", "princess", "master", "shadow", "superman",
+	"batman", "trustno", "login", "hello", "freedom", "whatever", "starwars", "secret",
+	"summer", "winter", "spring", "autumn", "contraseña", "clave", "usuario", "bienvenido",
+	"hola", "teamo", "amor", "futbol", "argentina", "boca", "river", "pass",
+}
+
+// Secuencias que se consideran triviales al recorrerlas en cualquier sentido
+var sequenceAlphabets = []string{
+	"abcdefghijklmnopqrstuvwxyz",
+	"01234567890",
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+}
+
+// EstimateStrength puntúa de 0 a 4 al estilo de zxcvbn: estima cuántos intentos necesitaría
+// un atacante descomponiendo la contraseña en palabras conocidas, repeticiones, secuencias y años
+func EstimateStrength(password string, userInputs ...string) int {
+	return strengthScore(estimateGuessesLog10(password, userInputs))
+}
+
+// Umbrales de zxcvbn en log10 de intentos
+func strengthScore(guessesLog10 float64) int {
+	switch {
+	case guessesLog10 < 3:
+		return 0
+	case guessesLog10 < 6:
+		return 1
+	case guessesLog10 < 8:
+		return 2
+	case guessesLog10 < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func estimateGuessesLog10(password string, userInputs []string) float64 {
+	original := []rune(password)
+	runes := []rune(strings.ToLower(password))
+
+	var total float64
+	for i := 0; i < len(runes); {
+		length, guesses := matchPattern(runes[i:], userInputs)
+		if length == 0 {
+			// Fuerza bruta: diez intentos por carácter, como zxcvbn
+			total++
+			i++
+			continue
+		}
+		if hasMixedCase(original[i : i+length]) {
+			guesses += math.Log10(2)
+		}
+		total += guesses
+		i += length
+	}
+
+	return total
+}
+
+// matchPattern busca el patrón más largo al inicio de runes y devuelve su longitud y coste en log10
+func matchPattern(runes []rune, userInputs []string) (int, float64) {
+	bestLength, bestGuesses := 0, 0.0
+	consider := func(length int, guesses float64) {
+		if length > bestLength {
+			bestLength, bestGuesses = length, guesses
+		}
+	}
+
+	// Los datos del propio usuario son lo primero que prueba un atacante
+	for _, input := range userInputs {
+		if len([]rune(input)) >= 3 && hasPrefix(runes, input) {
+			consider(len([]rune(input)), math.Log10(2))
+		}
+	}
+	for rank, word := range commonPasswordWords {
+		if hasPrefix(runes, word) {
+			consider(len([]rune(word)), math.Log10(float64(rank+2)))
+		}
+	}
+	if n := repeatLength(runes); n >= 3 {
+		consider(n, math.Log10(float64(10*n)))
+	}
+	if n := sequenceLength(runes); n >= 3 {
+		consider(n, math.Log10(float64(20*n)))
+	}
+	if isYear(runes) {
+		consider(4, math.Log10(120))
+	}
+
+	return bestLength, bestGuesses
+}
+
+func hasPrefix(runes []rune, word string) bool {
+	return strings.HasPrefix(string(runes), word)
+}
+
+func repeatLength(runes []rune) int {
+	n := 1
+	for n < len(runes) && runes[n] == runes[0] {
+		n++
+	}
+	return n
+}
+
+func sequenceLength(runes []rune) int {
+	best := 0
+	for _, alphabet := range sequenceAlphabets {
+		letters := []rune(alphabet)
+		for _, direction := range []int{1, -1} {
+			n := 0
+			for n < len(runes) {
+				position := indexRune(letters, runes[n])
+				if position < 0 {
+					break
+				}
+				if n > 0 && position != indexRune(letters, runes[n-1])+direction {
+					break
+				}
+				n++
+			}
+			if n > best {
+				best = n
+			}
+		}
+	}
+	return best
+}
+
+func indexRune(letters []rune, r rune) int {
+	for i, letter := range letters {
+		if letter == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// isYear detecta años entre 1900 y 2099 al inicio de runes
+func isYear(runes []rune) bool {
+	if len(runes) < 4 {
+		return false
+	}
+	for _, r := range runes[:4] {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	prefix := string(runes[:2])
+	return prefix == "19" || prefix == "20"
+}
+
+func hasMixedCase(runes []rune) bool {
+	var upper, lower bool
+	for _, r := range runes {
+		upper = upper || unicode.IsUpper(r)
+		lower = lower || unicode.IsLower(r)
+	}
+	return upper && lower
+}
@@ -0,0 +1,66 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"os"
+	"strings"
+)
+
+var unsubscribeSecret []byte
+
+// InitUnsubscribeSecret carga UNSUBSCRIBE_SECRET, la clave que firma los enlaces para darse de baja.
+// Los enlaces no vencen, así que cambiar la clave invalida los de todos los emails ya enviados.
+func InitUnsubscribeSecret() {
+	if secret := os.Getenv("UNSUBSCRIBE_SECRET"); secret != "" {
+		unsubscribeSecret = []byte(secret)
+		return
+	}
+
+	if os.Getenv("GIN_MODE") == "release" {
+		log.Fatal("UNSUBSCRIBE_SECRET is required in release mode")
+	}
+	unsubscribeSecret = make([]byte, 32)
+	if _, err := rand.Read(unsubscribeSecret); err != nil {
+		log.Fatalf("Error generating unsubscribe secret: %v", err)
+	}
+	log.Println("No UNSUBSCRIBE_SECRET configured, using an ephemeral one (unsubscribe links will not survive restarts)")
+}
+
+// UnsubscribeToken firma el usuario, el tipo de aviso y el canal del que se da de baja
+func UnsubscribeToken(userID, notificationType, channel string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(userID + "\n" + notificationType + "\n" + channel))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(unsubscribeMAC(payload))
+}
+
+func ParseUnsubscribeToken(token string) (userID, notificationType, channel string, err error) {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return "", "", "", ErrInvalidToken
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, unsubscribeMAC(payload)) {
+		return "", "", "", ErrInvalidToken
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", "", "", ErrInvalidToken
+	}
+	parts := strings.Split(string(decoded), "\n")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", "", "", ErrInvalidToken
+	}
+
+	return parts[0], parts[1], parts[2], nil
+}
+
+func unsubscribeMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, unsubscribeSecret)
+	mac.Write([]byte("unsubscribe:" + payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+)
+
+// StartAccountErasure borra periódicamente las cuentas cuyo periodo de gracia terminó
+func StartAccountErasure(interval time.Duration) {
+	go func() {
+		for {
+			runAccountErasure()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func runAccountErasure() {
+	userIDs, err := models.GetUsersDueForErasure(time.Now())
+	if err != nil {
+		log.Printf("Failed to list accounts due for erasure: %v", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		if err := models.EraseUser(userID); err != nil {
+			log.Printf("Failed to erase account %s: %v", userID, err)
+			continue
+		}
+		log.Printf("Erased account %s", userID)
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/notifications"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/webhooks"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+// ErrStatusChanged indica que otro cambio llegó primero y el evento ya no está en el estado leído
+var ErrStatusChanged = errors.New("event status changed")
+
+// ChangeEventStatus aplica la transición (que el llamador ya validó) y encola en la misma transacción
+// los avisos que corresponden: seguidores al publicar un borrador, inscriptos al cancelar o postergar.
+// Devuelve el evento con el estado nuevo.
+func ChangeEventStatus(event *models.Event, status, reason string) (*models.Event, error) {
+	after := *event
+	after.Status = status
+	after.StatusReason = reason
+	after.PublishAt = nil
+	after.UpdatedAt = time.Now().Format(time.RFC3339)
+
+	err := database.WithTx(func(tx *sql.Tx) error {
+		changed, err := models.SetEventStatus(tx, event.ID, event.Status, status, reason, nil)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return ErrStatusChanged
+		}
+
+		switch {
+		case status == models.EventStatusPublished && event.Status == models.EventStatusDraft:
+			err = notifications.EventPublished(tx, &after)
+		case status == models.EventStatusCancelled:
+			err = notifications.EventCancelled(tx, &after)
+		case status == models.EventStatusPostponed:
+			err = notifications.EventPostponed(tx, &after)
+		}
+		if err != nil {
+			return err
+		}
+
+		return webhooks.Dispatch(tx, event.UserID, webhooks.EventEventStatusChanged, map[string]interface{}{
+			"event":           after,
+			"previous_status": event.Status,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &after, nil
+}
+
+// StartScheduledPublishing publica los borradores cuya fecha de publicación ya llegó
+func StartScheduledPublishing(interval time.Duration) {
+	go func() {
+		for {
+			publishScheduledEvents()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// StartEventArchiving archiva periódicamente los eventos que ya terminaron
+func StartEventArchiving(interval time.Duration) {
+	go func() {
+		for {
+			archiveFinishedEvents()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func publishScheduledEvents() {
+	eventIDs, err := models.GetEventIDsDueForPublishing(time.Now())
+	if err != nil {
+		log.Printf("Failed to list scheduled events: %v", err)
+		return
+	}
+
+	for _, eventID := range eventIDs {
+		event, err := models.GetEventByID(eventID)
+		if err != nil {
+			log.Printf("Failed to load scheduled event %s: %v", eventID, err)
+			continue
+		}
+		if event == nil {
+			continue
+		}
+		// Igual que al publicar a mano: si las fechas ya pasaron queda como borrador sin programar
+		if !event.HasUpcomingDates() {
+			if _, err := models.SchedulePublish(event.ID, nil); err != nil {
+				log.Printf("Failed to unschedule event %s: %v", eventID, err)
+				continue
+			}
+			log.Printf("Scheduled event %s has no upcoming dates, left as a draft", eventID)
+			continue
+		}
+
+		_, err = ChangeEventStatus(event, models.EventStatusPublished, "")
+		if errors.Is(err, ErrStatusChanged) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to publish scheduled event %s: %v", eventID, err)
+			continue
+		}
+		log.Printf("Published scheduled event %s", eventID)
+	}
+}
+
+// archiveFinishedEvents saca de los listados los eventos publicados o postergados cuya última fecha ya pasó
+func archiveFinishedEvents() {
+	// Se espera un día desde el comienzo de la última fecha para no archivar un evento en curso.
+	// La consulta descarta por día los que no terminaron; la hora exacta se comprueba abajo.
+	now := time.Now()
+	cutoff := now.Add(-24 * time.Hour)
+	eventIDs, err := models.GetEventIDsEndedBy(cutoff.In(models.EventTimezone()))
+	if err != nil {
+		log.Printf("Failed to list finished events: %v", err)
+		return
+	}
+
+	for _, eventID := range eventIDs {
+		event, err := models.GetEventByID(eventID)
+		if err != nil {
+			log.Printf("Failed to load event %s: %v", eventID, err)
+			continue
+		}
+		if event == nil {
+			continue
+		}
+		last, ok := event.LastOccurrence()
+		if !ok || last.After(cutoff) {
+			continue
+		}
+
+		if _, err := ChangeEventStatus(event, models.EventStatusArchived, ""); err != nil && !errors.Is(err, ErrStatusChanged) {
+			log.Printf("Failed to archive event %s: %v", eventID, err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Place es el resultado de geocodificar una dirección o unas coordenadas
+type Place struct {
+	Address  string  `json:"address"`
+	Lat      float64 `json:"lat"`
+	Lng      float64 `json:"lng"`
+	City     string  `json:"city"`
+	Province string  `json:"province"`
+}
+
+// Geocoder traduce direcciones a coordenadas y coordenadas a ciudad y provincia
+type Geocoder interface {
+	Geocode(ctx context.Context, address string) (*Place, error)
+	Reverse(ctx context.Context, lat, lng float64) (*Place, error)
+}
+
+var (
+	// ErrPlaceNotFound indica que la dirección o las coordenadas no corresponden a ningún lugar conocido
+	ErrPlaceNotFound = errors.New("place not found")
+	// ErrGeocoderUnavailable indica que el proveedor no respondió bien
+	ErrGeocoderUnavailable = errors.New("geocoder unavailable")
+)
+
+// Geo es nil si no hay geocodificador configurado
+var Geo Geocoder
+
+// InitGeocoder elige el proveedor según GEOCODER_PROVIDER: nominatim (OpenStreetMap) o fixtures,
+// que responde con los lugares de un archivo JSON (GEOCODER_FIXTURES) sin salir a la red
+func InitGeocoder() {
+	switch os.Getenv("GEOCODER_PROVIDER") {
+	case "":
+		log.Println("No GEOCODER_PROVIDER configured, events must include their coordinates")
+	case "nominatim":
+		ttl := 24 * time.Hour
+		if value, err := time.ParseDuration(os.Getenv("GEOCODER_CACHE_TTL")); err == nil && value > 0 {
+			ttl = value
+		}
+		Geo = NewCachedGeocoder(NewNominatim(), ttl)
+	case "fixtures":
+		if os.Getenv("GIN_MODE") == "release" {
+			log.Fatal("GEOCODER_PROVIDER=fixtures cannot be used in release mode")
+		}
+		fixtures, err := LoadGeocoderFixtures(os.Getenv("GEOCODER_FIXTURES"))
+		if err != nil {
+			log.Fatalf("Error loading geocoder fixtures: %v", err)
+		}
+		Geo = fixtures
+	default:
+		log.Fatalf("Unknown GEOCODER_PROVIDER %q (use nominatim or fixtures)", os.Getenv("GEOCODER_PROVIDER"))
+	}
+}
+
+// ValidCoordinates comprueba los rangos de latitud y longitud
+func ValidCoordinates(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// CachedGeocoder guarda los lugares encontrados por dirección y por coordenadas (~10 m), así editar
+// eventos o cargar varios en el mismo lugar no repite consultas al proveedor
+type CachedGeocoder struct {
+	provider Geocoder
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedPlace
+}
+
+type cachedPlace struct {
+	place     Place
+	expiresAt time.Time
+}
+
+// maxCachedPlaces limita la memoria; al llegar se descartan los vencidos
+const maxCachedPlaces = 1000
+
+func NewCachedGeocoder(provider Geocoder, ttl time.Duration) *CachedGeocoder {
+	return &CachedGeocoder{provider: provider, ttl: ttl, entries: map[string]cachedPlace{}}
+}
+
+func (c *CachedGeocoder) Geocode(ctx context.Context, address string) (*Place, error) {
+	key := "geocode:" + strings.ToLower(strings.TrimSpace(address))
+	return c.cached(key, func() (*Place, error) { return c.provider.Geocode(ctx, address) })
+}
+
+func (c *CachedGeocoder) Reverse(ctx context.Context, lat, lng float64) (*Place, error) {
+	key := fmt.Sprintf("reverse:%.4f,%.4f", lat, lng)
+	return c.cached(key, func() (*Place, error) { return c.provider.Reverse(ctx, lat, lng) })
+}
+
+// cached devuelve una copia del lugar guardado en key o lo busca con lookup; los errores no se guardan
+func (c *CachedGeocoder) cached(key string, lookup func() (*Place, error)) (*Place, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		place := entry.place
+		return &place, nil
+	}
+
+	place, err := lookup()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= maxCachedPlaces {
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) < maxCachedPlaces {
+		c.entries[key] = cachedPlace{place: *place, expiresAt: now.Add(c.ttl)}
+	}
+	c.mu.Unlock()
+
+	return place, nil
+}
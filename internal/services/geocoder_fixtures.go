@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"math"
+	"os"
+	"strings"
+)
+
+//go:embed geocoder_fixtures.json
+var defaultGeocoderFixtures []byte
+
+// FixtureGeocoder responde con una lista fija de lugares, para desarrollo y pruebas. Geocode busca
+// la dirección exacta (sin distinguir mayúsculas) y Reverse el lugar más cercano a menos de ~50 km.
+type FixtureGeocoder struct {
+	Places []Place
+}
+
+// maxFixtureDistance es la distancia máxima, en grados, para que Reverse devuelva un lugar
+const maxFixtureDistance = 0.5
+
+// LoadGeocoderFixtures lee los lugares de path, o los incluidos en el binario si path está vacío
+func LoadGeocoderFixtures(path string) (*FixtureGeocoder, error) {
+	data := defaultGeocoderFixtures
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var fixtures FixtureGeocoder
+	if err := json.Unmarshal(data, &fixtures.Places); err != nil {
+		return nil, err
+	}
+	return &fixtures, nil
+}
+
+func (f *FixtureGeocoder) Geocode(ctx context.Context, address string) (*Place, error) {
+	for _, place := range f.Places {
+		if strings.EqualFold(strings.TrimSpace(place.Address), strings.TrimSpace(address)) {
+			found := place
+			return &found, nil
+		}
+	}
+	return nil, ErrPlaceNotFound
+}
+
+func (f *FixtureGeocoder) Reverse(ctx context.Context, lat, lng float64) (*Place, error) {
+	var closest *Place
+	closestDistance := maxFixtureDistance
+	for i, place := range f.Places {
+		distance := math.Hypot(place.Lat-lat, place.Lng-lng)
+		if distance <= closestDistance {
+			closest = &f.Places[i]
+			closestDistance = distance
+		}
+	}
+	if closest == nil {
+		return nil, ErrPlaceNotFound
+	}
+
+	found := *closest
+	found.Lat, found.Lng = lat, lng
+	return &found, nil
+}
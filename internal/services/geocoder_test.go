@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingGeocoder cuenta las consultas a los lugares incluidos
+type countingGeocoder struct {
+	fixtures *FixtureGeocoder
+	calls    atomic.Int32
+}
+
+func (g *countingGeocoder) Geocode(ctx context.Context, address string) (*Place, error) {
+	g.calls.Add(1)
+	return g.fixtures.Geocode(ctx, address)
+}
+
+func (g *countingGeocoder) Reverse(ctx context.Context, lat, lng float64) (*Place, error) {
+	g.calls.Add(1)
+	return g.fixtures.Reverse(ctx, lat, lng)
+}
+
+func TestCachedGeocoderReusesPlaces(t *testing.T) {
+	fixtures, err := LoadGeocoderFixtures("")
+	if err != nil {
+		t.Fatalf("LoadGeocoderFixtures: %v", err)
+	}
+	provider := &countingGeocoder{fixtures: fixtures}
+	cached := NewCachedGeocoder(provider, time.Minute)
+	ctx := context.Background()
+
+	for _, address := range []string{"Parque Sarmiento, Córdoba", " parque sarmiento, córdoba "} {
+		place, err := cached.Geocode(ctx, address)
+		if err != nil || place.City != "Córdoba" {
+			t.Fatalf("Geocode(%q) = %+v, %v", address, place, err)
+		}
+		// Modificar la copia no altera lo guardado
+		place.City = "Otra"
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cached.Reverse(ctx, -31.43051, -64.17801); err != nil {
+			t.Fatalf("Reverse: %v", err)
+		}
+	}
+	if got := provider.calls.Load(); got != 2 {
+		t.Errorf("provider calls = %d, want 2", got)
+	}
+
+	// Los lugares no encontrados se vuelven a consultar
+	for i := 0; i < 2; i++ {
+		if _, err := cached.Geocode(ctx, "Calle Falsa 123"); !errors.Is(err, ErrPlaceNotFound) {
+			t.Fatalf("Geocode err = %v, want ErrPlaceNotFound", err)
+		}
+	}
+	if got := provider.calls.Load(); got != 4 {
+		t.Errorf("provider calls = %d, want 4", got)
+	}
+}
+
+func TestNominatimSpacesRequests(t *testing.T) {
+	var requests []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, time.Now())
+		w.Write([]byte(`[{"display_name": "Plaza de Mayo", "lat": "-34.6083", "lon": "-58.3712", "address": {"city": "Buenos Aires"}}]`))
+	}))
+	defer server.Close()
+
+	nominatim := &Nominatim{BaseURL: server.URL, UserAgent: "test", Client: server.Client()}
+	for i := 0; i < 2; i++ {
+		if _, err := nominatim.Geocode(context.Background(), "Plaza de Mayo"); err != nil {
+			t.Fatalf("Geocode: %v", err)
+		}
+	}
+	if gap := requests[1].Sub(requests[0]); gap < nominatimInterval-10*time.Millisecond {
+		t.Errorf("gap between requests = %v, want at least %v", gap, nominatimInterval)
+	}
+
+	// Si el contexto vence antes del turno no se consulta
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := nominatim.Geocode(ctx, "Plaza de Mayo"); !errors.Is(err, ErrGeocoderUnavailable) {
+		t.Errorf("Geocode err = %v, want ErrGeocoderUnavailable", err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("requests = %d, want 2", len(requests))
+	}
+}
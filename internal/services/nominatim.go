@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const nominatimURL = "https://nominatim.openstreetmap.org"
+
+// nominatimInterval es la separación mínima entre consultas que pide la política de uso
+const nominatimInterval = time.Second
+
+// Nominatim usa el servicio de OpenStreetMap. Su política de uso pide identificarse con un
+// User-Agent propio (GEOCODER_USER_AGENT) y no superar una consulta por segundo: las consultas
+// de este proceso se espacian y esperan su turno mientras el contexto lo permita.
+type Nominatim struct {
+	BaseURL   string
+	UserAgent string
+	Client    *http.Client
+
+	mu          sync.Mutex
+	nextRequest time.Time
+}
+
+func NewNominatim() *Nominatim {
+	return &Nominatim{
+		BaseURL:   envOr("GEOCODER_URL", nominatimURL),
+		UserAgent: envOr("GEOCODER_USER_AGENT", "restApi-Go-events"),
+		Client:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+type nominatimPlace struct {
+	DisplayName string `json:"display_name"`
+	Lat         string `json:"lat"`
+	Lon         string `json:"lon"`
+	Address     struct {
+		City         string `json:"city"`
+		Town         string `json:"town"`
+		Village      string `json:"village"`
+		Municipality string `json:"municipality"`
+		State        string `json:"state"`
+	} `json:"address"`
+	Error string `json:"error"`
+}
+
+func (n *Nominatim) Geocode(ctx context.Context, address string) (*Place, error) {
+	query := url.Values{}
+	query.Set("q", address)
+	query.Set("format", "jsonv2")
+	query.Set("addressdetails", "1")
+	query.Set("limit", "1")
+
+	var places []nominatimPlace
+	if err := n.get(ctx, "/search", query, &places); err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, ErrPlaceNotFound
+	}
+	return places[0].toPlace()
+}
+
+func (n *Nominatim) Reverse(ctx context.Context, lat, lng float64) (*Place, error) {
+	query := url.Values{}
+	query.Set("lat", strconv.FormatFloat(lat, 'f', 6, 64))
+	query.Set("lon", strconv.FormatFloat(lng, 'f', 6, 64))
+	query.Set("format", "jsonv2")
+	query.Set("addressdetails", "1")
+	query.Set("zoom", "10")
+
+	var place nominatimPlace
+	if err := n.get(ctx, "/reverse", query, &place); err != nil {
+		return nil, err
+	}
+	// En el medio del mar Nominatim responde 200 con un error
+	if place.Error != "" {
+		return nil, ErrPlaceNotFound
+	}
+	return place.toPlace()
+}
+
+// wait reserva el próximo turno libre y espera hasta que llegue
+func (n *Nominatim) wait(ctx context.Context) error {
+	n.mu.Lock()
+	slot := time.Now()
+	if n.nextRequest.After(slot) {
+		slot = n.nextRequest
+	}
+	n.nextRequest = slot.Add(nominatimInterval)
+	n.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrGeocoderUnavailable, ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (n *Nominatim) get(ctx context.Context, path string, query url.Values, target interface{}) error {
+	if err := n.wait(ctx); err != nil {
+		return err
+	}
+
+	query.Set("accept-language", "es")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.BaseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", n.UserAgent)
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrGeocoderUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("%w: nominatim returned %d: %s", ErrGeocoderUnavailable, resp.StatusCode, body)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("%w: %v", ErrGeocoderUnavailable, err)
+	}
+	return nil
+}
+
+func (p nominatimPlace) toPlace() (*Place, error) {
+	lat, err := strconv.ParseFloat(p.Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid latitude %q", ErrGeocoderUnavailable, p.Lat)
+	}
+	lng, err := strconv.ParseFloat(p.Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid longitude %q", ErrGeocoderUnavailable, p.Lon)
+	}
+
+	city := p.Address.City
+	for _, candidate := range []string{p.Address.Town, p.Address.Village, p.Address.Municipality} {
+		if city == "" {
+			city = candidate
+		}
+	}
+
+	return &Place{Address: p.DisplayName, Lat: lat, Lng: lng, City: city, Province: p.Address.State}, nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+)
+
+const openWeatherMapURL = "https://api.openweathermap.org/data/2.5/forecast"
+
+// forecastStep es el intervalo entre entradas del pronóstico de 5 días
+const forecastStep = 3 * time.Hour
+
+type OpenWeatherMap struct {
+	APIKey  string
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewOpenWeatherMap(apiKey string) *OpenWeatherMap {
+	return &OpenWeatherMap{
+		APIKey:  apiKey,
+		BaseURL: openWeatherMapURL,
+		Client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+type openWeatherForecast struct {
+	City struct {
+		Name string `json:"name"`
+	} `json:"city"`
+	List []struct {
+		Dt   int64 `json:"dt"`
+		Main struct {
+			Temp float64 `json:"temp"`
+		} `json:"main"`
+		Weather []models.WeatherCondition `json:"weather"`
+		Pop     float64                   `json:"pop"`
+		Rain    struct {
+			ThreeHours float64 `json:"3h"`
+		} `json:"rain"`
+	} `json:"list"`
+}
+
+func (p *OpenWeatherMap) Forecast(ctx context.Context, lat, lon float64) (*Forecast, error) {
+	query := url.Values{}
+	query.Set("lat", strconv.FormatFloat(lat, 'f', 4, 64))
+	query.Set("lon", strconv.FormatFloat(lon, 'f', 4, 64))
+	query.Set("units", "metric")
+	query.Set("appid", p.APIKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, withoutURL(err)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWeatherUnavailable, withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return nil, fmt.Errorf("%w: openweathermap returned %d: %s", ErrWeatherUnavailable, resp.StatusCode, body)
+	}
+
+	var forecast openWeatherForecast
+	if err := json.NewDecoder(resp.Body).Decode(&forecast); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWeatherUnavailable, err)
+	}
+	if len(forecast.List) == 0 {
+		return nil, fmt.Errorf("%w: empty forecast", ErrWeatherUnavailable)
+	}
+
+	result := &Forecast{Entries: make([]models.WeatherResponse, 0, len(forecast.List))}
+	for _, entry := range forecast.List {
+		weather := models.WeatherResponse{
+			City:                     models.WeatherCity{Name: forecast.City.Name},
+			Main:                     models.WeatherMain{Temp: entry.Main.Temp},
+			Weather:                  entry.Weather,
+			Link:                     weatherMapLink(lat, lon),
+			ForecastAt:               time.Unix(entry.Dt, 0).UTC(),
+			PrecipitationProbability: entry.Pop,
+			RainMM:                   entry.Rain.ThreeHours,
+		}
+		if weather.Weather == nil {
+			weather.Weather = []models.WeatherCondition{}
+		}
+		result.Entries = append(result.Entries, weather)
+	}
+	sort.Slice(result.Entries, func(i, j int) bool { return result.Entries[i].ForecastAt.Before(result.Entries[j].ForecastAt) })
+
+	return result, nil
+}
+
+// withoutURL quita la URL de los errores de net/http: lleva la API key en la query y terminaría en los logs
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
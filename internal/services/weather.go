@@ -1,80 +1,250 @@
 package services
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
+	"log"
+	"math"
 	"os"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
 )
 
-func GetWeather(lat, lon float64, date string) (*models.WeatherResponse, error) {
-	apiKey := os.Getenv("WEATHER_API_KEY")
-	url := fmt.Sprintf("http://api.openweathermap.org/data/2.5/forecast?lat=%f&lon=%f&units=metric&appid=%s", lat, lon, apiKey)
+// WeatherProvider devuelve el pronóstico de 5 días de un lugar; una sola consulta sirve para todas las fechas
+type WeatherProvider interface {
+	Forecast(ctx context.Context, lat, lon float64) (*Forecast, error)
+}
 
-	// Convertir la fecha del formato "DD/MM/YYYY" a "YYYY-MM-DD"
-	parsedDate, err := time.Parse("02/01/2006", date)
-	if err != nil {
-		return nil, fmt.Errorf("invalid date format: %v", err)
+// Forecast son las entradas del pronóstico de un lugar cada 3 horas, ordenadas por hora
+type Forecast struct {
+	Entries []models.WeatherResponse
+}
+
+// At elige la entrada más cercana al momento indicado
+func (f *Forecast) At(at time.Time) (*models.WeatherResponse, error) {
+	if len(f.Entries) == 0 {
+		return nil, ErrForecastOutOfRange
+	}
+
+	// Fuera del rango cubierto (con medio intervalo de margen) el pronóstico más cercano no sirve
+	first := f.Entries[0].ForecastAt
+	last := f.Entries[len(f.Entries)-1].ForecastAt
+	if at.Before(first.Add(-forecastStep/2)) || at.After(last.Add(forecastStep/2)) {
+		return nil, ErrForecastOutOfRange
+	}
+
+	closest := 0
+	for i, entry := range f.Entries {
+		if entry.ForecastAt.Sub(at).Abs() < f.Entries[closest].ForecastAt.Sub(at).Abs() {
+			closest = i
+		}
+	}
+	entry := f.Entries[closest]
+	return &entry, nil
+}
+
+var (
+	// ErrWeatherUnavailable indica que no hay proveedor configurado o que el proveedor no respondió bien
+	ErrWeatherUnavailable = errors.New("weather forecast unavailable")
+	// ErrForecastOutOfRange indica que el momento está fuera de la ventana del pronóstico
+	ErrForecastOutOfRange = errors.New("date is outside the forecast window")
+)
+
+// ForecastWindow es hasta dónde llega el pronóstico de 5 días
+const ForecastWindow = 5 * 24 * time.Hour
+
+// Weather es nil si no hay proveedor configurado
+var Weather WeatherProvider
+
+// InitWeather elige el proveedor según WEATHER_PROVIDER: openweathermap (por defecto si hay
+// WEATHER_API_KEY) o fake, que genera pronósticos fijos sin salir a la red
+func InitWeather() {
+	ttl := 30 * time.Minute
+	if value, err := time.ParseDuration(os.Getenv("WEATHER_CACHE_TTL")); err == nil && value > 0 {
+		ttl = value
 	}
-	formattedDate := parsedDate.Format("2006-01-02")
 
-	resp, err := http.Get(fmt.Sprintf("%s&date=%s", url, formattedDate))
+	var provider WeatherProvider
+	switch os.Getenv("WEATHER_PROVIDER") {
+	case "":
+		if os.Getenv("WEATHER_API_KEY") == "" {
+			log.Println("No WEATHER_API_KEY configured, events will be returned without weather")
+			return
+		}
+		provider = NewOpenWeatherMap(os.Getenv("WEATHER_API_KEY"))
+	case "openweathermap":
+		if os.Getenv("WEATHER_API_KEY") == "" {
+			log.Fatal("WEATHER_API_KEY is required when WEATHER_PROVIDER=openweathermap")
+		}
+		provider = NewOpenWeatherMap(os.Getenv("WEATHER_API_KEY"))
+	case "fake":
+		if os.Getenv("GIN_MODE") == "release" {
+			log.Fatal("WEATHER_PROVIDER=fake cannot be used in release mode")
+		}
+		// El falso no pasa por la caché para que los cambios de escenario se vean enseguida
+		Weather = FakeWeather{}
+		return
+	default:
+		log.Fatalf("Unknown WEATHER_PROVIDER %q (use openweathermap or fake)", os.Getenv("WEATHER_PROVIDER"))
+	}
+
+	Weather = NewCachedWeather(provider, ttl)
+}
+
+// GetWeather usa el proveedor configurado para el pronóstico de un momento
+func GetWeather(ctx context.Context, lat, lon float64, at time.Time) (*models.WeatherResponse, error) {
+	if Weather == nil {
+		return nil, ErrWeatherUnavailable
+	}
+	forecast, err := Weather.Forecast(ctx, lat, lon)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	var forecast struct {
-		City struct {
-			Name string `json:"name"`
-		} `json:"city"`
-		List []struct {
-			Dt   int64 `json:"dt"`
-			Main struct {
-				Temp float64 `json:"temp"`
-			} `json:"main"`
-			Weather []struct {
-				Main        string `json:"main"`
-				Description string `json:"description"`
-			} `json:"weather"`
-		} `json:"list"`
+	return forecast.At(at)
+}
+
+func weatherMapLink(lat, lon float64) string {
+	return fmt.Sprintf("https://openweathermap.org/weathermap?basemap=map&cities=true&layer=temperature&lat=%f&lon=%f&zoom=10", lat, lon)
+}
+
+// CachedWeather guarda los pronósticos por coordenadas redondeadas (~1 km), así las fechas y los
+// eventos del mismo lugar comparten resultados y no se consulta la API en cada petición
+type CachedWeather struct {
+	provider WeatherProvider
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedForecast
+}
+
+type cachedForecast struct {
+	forecast  *Forecast
+	expiresAt time.Time
+}
+
+// maxCachedForecasts limita la memoria; al llegar se descartan los vencidos
+const maxCachedForecasts = 5000
+
+func NewCachedWeather(provider WeatherProvider, ttl time.Duration) *CachedWeather {
+	return &CachedWeather{provider: provider, ttl: ttl, entries: map[string]cachedForecast{}}
+}
+
+func (c *CachedWeather) Forecast(ctx context.Context, lat, lon float64) (*Forecast, error) {
+	key := fmt.Sprintf("%.2f,%.2f", math.Round(lat*100)/100, math.Round(lon*100)/100)
+
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.forecast, nil
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&forecast); err != nil {
+	forecast, err := c.provider.Forecast(ctx, lat, lon)
+	if err != nil {
 		return nil, err
 	}
 
-	var closestForecast *models.WeatherResponse
-	minDiff := int64(1<<63 - 1)
-	for _, entry := range forecast.List {
-		diff := abs(entry.Dt - parsedDate.Unix())
-		if diff < minDiff {
-			minDiff = diff
-			closestForecast = &models.WeatherResponse{
-				City: struct {
-					Name string `json:"name"`
-				}{Name: forecast.City.Name},
-				Main: struct {
-					Temp float64 `json:"temp"`
-				}{Temp: entry.Main.Temp},
-				Weather: []struct {
-					Main        string `json:"main"`
-					Description string `json:"description"`
-				}{{Main: entry.Weather[0].Main, Description: entry.Weather[0].Description}},
-				Link: fmt.Sprintf("https://openweathermap.org/weathermap?basemap=map&cities=true&layer=temperature&lat=%f&lon=%f&zoom=10", lat, lon),
+	c.mu.Lock()
+	if len(c.entries) >= maxCachedForecasts {
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
 			}
 		}
 	}
+	if len(c.entries) < maxCachedForecasts {
+		c.entries[key] = cachedForecast{forecast: forecast, expiresAt: now.Add(c.ttl)}
+	}
+	c.mu.Unlock()
+
+	return forecast, nil
+}
+
+const (
+	WeatherOK          = "ok"
+	WeatherUnavailable = "unavailable"
+	WeatherOutOfRange  = "out_of_range"
+)
+
+// EventWeather es el clima de las próximas fechas de un evento
+type EventWeather struct {
+	Status string        `json:"status"`
+	Dates  []DateWeather `json:"dates"`
+}
 
-	return closestForecast, nil
+type DateWeather struct {
+	Date     string                  `json:"date"`
+	Status   string                  `json:"status"`
+	Forecast *models.WeatherResponse `json:"forecast,omitempty"`
 }
 
-func abs(x int64) int64 {
-	if x < 0 {
-		return -x
+// GetEventWeather consulta una vez el pronóstico del lugar y toma de ahí el de cada fecha futura del
+// evento dentro de la ventana de 5 días. Nunca falla: cada fecha lleva su estado y Status resume el
+// conjunto. El límite de tiempo lo pone ctx; si el proveedor no responde las fechas quedan como unavailable.
+func GetEventWeather(ctx context.Context, event *models.Event) *EventWeather {
+	now := time.Now()
+
+	type upcoming struct {
+		date       string
+		occurrence time.Time
+	}
+	var dates []upcoming
+	for date := range event.DateTimes {
+		occurrence, ok := event.OccurrenceTime(date)
+		if ok && occurrence.After(now) {
+			dates = append(dates, upcoming{date: date, occurrence: occurrence})
+		}
 	}
-	return x
+	sort.Slice(dates, func(i, j int) bool { return dates[i].occurrence.Before(dates[j].occurrence) })
+
+	result := &EventWeather{Dates: make([]DateWeather, len(dates))}
+	var forecast *Forecast
+	var forecastErr error
+	for i, d := range dates {
+		result.Dates[i] = DateWeather{Date: d.date, Status: WeatherOutOfRange}
+		if d.occurrence.After(now.Add(ForecastWindow)) {
+			continue
+		}
+		result.Dates[i].Status = WeatherUnavailable
+		if Weather == nil || !event.Location.HasCoordinates() {
+			continue
+		}
+
+		// Las fechas están ordenadas: la primera dentro de la ventana hace la única consulta
+		if forecast == nil && forecastErr == nil {
+			forecast, forecastErr = Weather.Forecast(ctx, event.Location.Lat, event.Location.Lng)
+			if forecastErr != nil {
+				log.Printf("Error getting weather for event %s: %v", event.ID, forecastErr)
+			}
+		}
+		if forecastErr != nil {
+			continue
+		}
+
+		if entry, err := forecast.At(d.occurrence); err == nil {
+			result.Dates[i].Status = WeatherOK
+			result.Dates[i].Forecast = entry
+		} else {
+			result.Dates[i].Status = WeatherOutOfRange
+		}
+	}
+
+	// ok si alguna fecha tiene pronóstico, out_of_range si ninguna entra en la ventana
+	result.Status = WeatherOutOfRange
+	for _, dateWeather := range result.Dates {
+		if dateWeather.Status == WeatherOK {
+			result.Status = WeatherOK
+			break
+		}
+		if dateWeather.Status == WeatherUnavailable {
+			result.Status = WeatherUnavailable
+		}
+	}
+
+	return result
 }
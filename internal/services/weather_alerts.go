@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/notifications"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/google/uuid"
+)
+
+const (
+	AlertRain = "rain"
+	AlertHeat = "heat"
+)
+
+// WeatherAlertThresholds son los límites a partir de los cuales se avisa. Se configuran con
+// WEATHER_ALERT_RAIN_PROBABILITY (0 a 1), WEATHER_ALERT_RAIN_MM y WEATHER_ALERT_HEAT (°C).
+type WeatherAlertThresholds struct {
+	RainProbability float64
+	RainMM          float64
+	Heat            float64
+}
+
+func weatherAlertThresholds() WeatherAlertThresholds {
+	return WeatherAlertThresholds{
+		RainProbability: envFloat("WEATHER_ALERT_RAIN_PROBABILITY", 0.7),
+		RainMM:          envFloat("WEATHER_ALERT_RAIN_MM", 5),
+		Heat:            envFloat("WEATHER_ALERT_HEAT", 35),
+	}
+}
+
+func envFloat(name string, fallback float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Ignoring invalid %s %q", name, value)
+		return fallback
+	}
+	return parsed
+}
+
+// Alerts devuelve las condiciones del pronóstico que superan los umbrales
+func (t WeatherAlertThresholds) Alerts(forecast *models.WeatherResponse) []string {
+	var alerts []string
+	if forecast.PrecipitationProbability >= t.RainProbability || forecast.RainMM >= t.RainMM {
+		alerts = append(alerts, AlertRain)
+	}
+	if forecast.Main.Temp >= t.Heat {
+		alerts = append(alerts, AlertHeat)
+	}
+	return alerts
+}
+
+// weatherAlertsLock es el advisory lock que evita que varias réplicas revisen el clima a la vez
+const weatherAlertsLock int64 = 0x77656174686572
+
+// weatherCheckRetention lee WEATHER_CHECK_RETENTION, cuánto se guarda el historial de revisiones
+func weatherCheckRetention() time.Duration {
+	if value, err := time.ParseDuration(os.Getenv("WEATHER_CHECK_RETENTION")); err == nil && value > 0 {
+		return value
+	}
+	return 30 * 24 * time.Hour
+}
+
+// StartWeatherAlerts revisa periódicamente el pronóstico de las fechas de los eventos al aire libre
+// que caen dentro de WEATHER_ALERT_HORIZON (por defecto 72h) y avisa si supera los umbrales.
+// Con varias réplicas corre solo en la que toma el lock; esa misma borra el historial viejo.
+func StartWeatherAlerts(interval time.Duration) {
+	if Weather == nil {
+		log.Println("No weather provider configured, weather alerts are disabled")
+		return
+	}
+
+	horizon := 72 * time.Hour
+	if value, err := time.ParseDuration(os.Getenv("WEATHER_ALERT_HORIZON")); err == nil && value > 0 {
+		horizon = value
+	}
+	if horizon > ForecastWindow {
+		horizon = ForecastWindow
+	}
+	thresholds := weatherAlertThresholds()
+
+	go func() {
+		for {
+			_, err := database.WithAdvisoryLock(weatherAlertsLock, func() {
+				runWeatherAlerts(horizon, thresholds)
+				deleteOldWeatherChecks()
+			})
+			if err != nil {
+				log.Printf("Failed to run weather alerts: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func runWeatherAlerts(horizon time.Duration, thresholds WeatherAlertThresholds) {
+	eventIDs, err := models.GetOutdoorEventIDs()
+	if err != nil {
+		log.Printf("Failed to list outdoor events: %v", err)
+		return
+	}
+
+	for _, eventID := range eventIDs {
+		event, err := models.GetEventByID(eventID)
+		if err != nil {
+			log.Printf("Failed to load event %s for weather alerts: %v", eventID, err)
+			continue
+		}
+		if event == nil || !event.Location.HasCoordinates() {
+			continue
+		}
+
+		now := time.Now()
+		for date := range event.DateTimes {
+			occurrence, ok := event.OccurrenceTime(date)
+			if !ok || occurrence.Before(now) || occurrence.After(now.Add(horizon)) {
+				continue
+			}
+			if err := checkEventWeather(event, date, occurrence, thresholds); err != nil {
+				log.Printf("Failed to check weather for event %s on %s: %v", event.ID, date, err)
+			}
+		}
+	}
+}
+
+func deleteOldWeatherChecks() {
+	cutoff := time.Now().Add(-weatherCheckRetention())
+	if deleted, err := models.DeleteWeatherChecksBefore(cutoff); err != nil {
+		log.Printf("Error cleaning up weather checks: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Deleted %d old weather checks", deleted)
+	}
+}
+
+// checkEventWeather guarda el resultado en el historial y avisa si corresponde
+func checkEventWeather(event *models.Event, date string, occurrence time.Time, thresholds WeatherAlertThresholds) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	check := models.WeatherCheck{
+		ID:        uuid.New().String(),
+		EventID:   event.ID,
+		EventDate: date,
+		Status:    WeatherOK,
+		CheckedAt: time.Now(),
+	}
+
+	forecast, err := GetWeather(ctx, event.Location.Lat, event.Location.Lng, occurrence)
+	switch {
+	case errors.Is(err, ErrForecastOutOfRange):
+		check.Status = WeatherOutOfRange
+	case err != nil:
+		log.Printf("Error getting weather for event %s on %s: %v", event.ID, date, err)
+		check.Status = WeatherUnavailable
+	default:
+		check.ForecastAt = &forecast.ForecastAt
+		check.Temperature = &forecast.Main.Temp
+		check.PrecipitationProbability = &forecast.PrecipitationProbability
+		check.RainMM = &forecast.RainMM
+		check.Alerts = thresholds.Alerts(forecast)
+	}
+
+	if err := check.Save(); err != nil {
+		return err
+	}
+	if len(check.Alerts) == 0 {
+		return nil
+	}
+
+	return database.WithTx(func(tx *sql.Tx) error {
+		return notifications.WeatherAlert(tx, event, date, forecast, check.Alerts)
+	})
+}
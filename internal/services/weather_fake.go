@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+)
+
+// FakeWeather genera un pronóstico determinista a partir del lugar y el día, sin salir a la red.
+// WEATHER_FAKE_CONDITION (clear, rain, heat) fuerza un escenario para probar las alertas.
+type FakeWeather struct{}
+
+var fakeConditions = []struct {
+	condition models.WeatherCondition
+	temp      float64
+	pop       float64
+	rain      float64
+}{
+	{models.WeatherCondition{Main: "Clear", Description: "cielo claro"}, 24, 0, 0},
+	{models.WeatherCondition{Main: "Clouds", Description: "nubes dispersas"}, 19, 0.2, 0},
+	{models.WeatherCondition{Main: "Rain", Description: "lluvia moderada"}, 15, 0.9, 6},
+}
+
+func (FakeWeather) Forecast(ctx context.Context, lat, lon float64) (*Forecast, error) {
+	start := time.Now().UTC().Truncate(forecastStep)
+	forecast := &Forecast{}
+	for at := start; !at.After(start.Add(ForecastWindow)); at = at.Add(forecastStep) {
+		forecast.Entries = append(forecast.Entries, fakeEntry(lat, lon, at))
+	}
+	return forecast, nil
+}
+
+// fakeEntry elige el escenario según el lugar y el día local, así todas las horas de un día coinciden
+func fakeEntry(lat, lon float64, at time.Time) models.WeatherResponse {
+	hash := fnv.New32a()
+	hash.Write([]byte(strconv.FormatFloat(lat, 'f', 2, 64) + strconv.FormatFloat(lon, 'f', 2, 64) + at.In(models.EventTimezone()).Format("2006-01-02")))
+	scenario := fakeConditions[hash.Sum32()%uint32(len(fakeConditions))]
+
+	switch os.Getenv("WEATHER_FAKE_CONDITION") {
+	case "clear":
+		scenario = fakeConditions[0]
+	case "rain":
+		scenario = fakeConditions[2]
+	case "heat":
+		scenario = fakeConditions[0]
+		scenario.temp = 38
+	}
+
+	return models.WeatherResponse{
+		City:                     models.WeatherCity{Name: "Fake City"},
+		Main:                     models.WeatherMain{Temp: scenario.temp},
+		Weather:                  []models.WeatherCondition{scenario.condition},
+		Link:                     weatherMapLink(lat, lon),
+		ForecastAt:               at,
+		PrecipitationProbability: scenario.pop,
+		RainMM:                   scenario.rain,
+	}
+}
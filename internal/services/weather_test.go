@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+)
+
+// countingWeather cuenta las consultas al proveedor falso
+type countingWeather struct {
+	calls atomic.Int32
+}
+
+func (w *countingWeather) Forecast(ctx context.Context, lat, lon float64) (*Forecast, error) {
+	w.calls.Add(1)
+	return FakeWeather{}.Forecast(ctx, lat, lon)
+}
+
+func TestGetEventWeatherFetchesForecastOnce(t *testing.T) {
+	provider := &countingWeather{}
+	previous := Weather
+	Weather = provider
+	t.Cleanup(func() { Weather = previous })
+
+	event := &models.Event{
+		ID:        "11111111-1111-4111-8111-111111111111",
+		Location:  models.Location{Lat: -34.60, Lng: -58.38},
+		DateTimes: map[string]models.DateTime{},
+	}
+	today := time.Now().In(models.EventTimezone())
+	for offset := 1; offset <= 3; offset++ {
+		event.DateTimes[today.AddDate(0, 0, offset).Format("02/01/2006")] = models.DateTime{Time: "18:30"}
+	}
+
+	result := GetEventWeather(context.Background(), event)
+	if got := provider.calls.Load(); got != 1 {
+		t.Errorf("provider calls = %d, want 1", got)
+	}
+	for _, date := range result.Dates {
+		if date.Status != WeatherOK || date.Forecast == nil {
+			t.Errorf("date %s status = %q", date.Date, date.Status)
+		}
+	}
+}
+
+func TestCachedWeatherSharesForecastAcrossDates(t *testing.T) {
+	provider := &countingWeather{}
+	cached := NewCachedWeather(provider, time.Minute)
+	ctx := context.Background()
+
+	for _, hours := range []int{3, 27, 51} {
+		forecast, err := cached.Forecast(ctx, -34.6037, -58.3816)
+		if err != nil {
+			t.Fatalf("Forecast: %v", err)
+		}
+		if _, err := forecast.At(time.Now().Add(time.Duration(hours) * time.Hour)); err != nil {
+			t.Errorf("At(+%dh): %v", hours, err)
+		}
+	}
+	if got := provider.calls.Load(); got != 1 {
+		t.Errorf("provider calls = %d, want 1", got)
+	}
+}
+
+func TestForecastAtOutOfRange(t *testing.T) {
+	forecast, _ := FakeWeather{}.Forecast(context.Background(), -34.60, -58.38)
+	if _, err := forecast.At(time.Now().Add(ForecastWindow + 6*time.Hour)); err != ErrForecastOutOfRange {
+		t.Errorf("At after the window err = %v, want ErrForecastOutOfRange", err)
+	}
+	if _, err := forecast.At(time.Now().Add(-6 * time.Hour)); err != ErrForecastOutOfRange {
+		t.Errorf("At in the past err = %v, want ErrForecastOutOfRange", err)
+	}
+}
+
+func TestOpenWeatherMapErrorsHideAPIKey(t *testing.T) {
+	// Un servidor cerrado hace fallar la conexión; el error de net/http incluye la URL con la key
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	for name, baseURL := range map[string]string{"connection refused": server.URL, "invalid URL": "http://[::1"} {
+		t.Run(name, func(t *testing.T) {
+			provider := &OpenWeatherMap{APIKey: "secret-api-key", BaseURL: baseURL, Client: server.Client()}
+			_, err := provider.Forecast(context.Background(), -34.60, -58.38)
+			if err == nil {
+				t.Fatal("Forecast succeeded")
+			}
+			if strings.Contains(err.Error(), "secret-api-key") {
+				t.Errorf("error exposes the API key: %v", err)
+			}
+		})
+	}
+
+	provider := &OpenWeatherMap{APIKey: "secret-api-key", BaseURL: server.URL, Client: server.Client()}
+	if _, err := provider.Forecast(context.Background(), -34.60, -58.38); !errors.Is(err, ErrWeatherUnavailable) {
+		t.Errorf("Forecast err = %v, want ErrWeatherUnavailable", err)
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// NormalizeE164 quita espacios, guiones, puntos y paréntesis y valida el formato E.164 (+5491122334455)
+func NormalizeE164(phone string) (string, bool) {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+
+	if !e164Pattern.MatchString(normalized) {
+		return "", false
+	}
+	return normalized, true
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SSEKeepAlive mantiene vivas las conexiones Server-Sent Events a través de proxies que cortan las inactivas
+const SSEKeepAlive = 25 * time.Second
+
+// StartSSE escribe las cabeceras de un stream Server-Sent Events
+func StartSSE(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Evita que nginx acumule la respuesta en su buffer
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+}
+
+// WriteSSE escribe un evento con data en JSON; id puede ir vacío
+func WriteSSE(w io.Writer, id, event string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, encoded)
+	return err
+}
+
+// WriteSSEKeepAlive escribe un comentario, que los clientes ignoran
+func WriteSSEKeepAlive(w io.Writer) error {
+	_, err := fmt.Fprint(w, ": keep-alive\n\n")
+	return err
+}
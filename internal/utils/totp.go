@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod = 30 // segundos por paso (RFC 6238)
+	totpDigits = 6
+	totpSkew   = 1 // pasos de tolerancia hacia atrás y adelante
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret genera un secreto aleatorio de 160 bits codificado en base32
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base32NoPadding.EncodeToString(secret), nil
+}
+
+// TOTPURL construye la URL otpauth:// que entienden las apps autenticadoras
+func TOTPURL(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, params.Encode())
+}
+
+// TOTPCode calcula el código para un paso de tiempo concreto (RFC 4226 / RFC 6238)
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %v", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod), nil
+}
+
+// ValidateTOTP verifica un código contra el instante dado y devuelve el paso que coincidió,
+// para que el llamador pueda rechazar códigos reutilizados
+func ValidateTOTP(secret, code string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := t.Unix() / totpPeriod
+	for i := -totpSkew; i <= totpSkew; i++ {
+		step := current + int64(i)
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// ValidateTOTPAfter es ValidateTOTP pero rechaza los pasos hasta lastStep, que ya se usaron
+func ValidateTOTPAfter(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	step, ok := ValidateTOTP(secret, code, t)
+	if !ok || step <= lastStep {
+		return 0, false
+	}
+	return step, true
+}
+
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// GenerateRecoveryCodes genera n códigos de recuperación con el formato xxxxx-xxxxx
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		var b strings.Builder
+		for j := 0; j < 10; j++ {
+			if j == 5 {
+				b.WriteByte('-')
+			}
+			symbol, err := randomSymbol(recoveryCodeAlphabet)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteByte(symbol)
+		}
+		codes = append(codes, b.String())
+	}
+	return codes, nil
+}
+
+// randomSymbol elige un carácter al azar. Descarta los bytes por encima del último múltiplo del
+// tamaño del alfabeto para que todos los caracteres tengan la misma probabilidad.
+func randomSymbol(alphabet string) (byte, error) {
+	limit := 256 - 256%len(alphabet)
+	var raw [1]byte
+	for {
+		if _, err := rand.Read(raw[:]); err != nil {
+			return 0, err
+		}
+		if int(raw[0]) < limit {
+			return alphabet[int(raw[0])%len(alphabet)], nil
+		}
+	}
+}
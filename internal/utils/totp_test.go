@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret es la clave de los vectores de prueba de SHA1 del RFC 6238 ("12345678901234567890")
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	// El RFC da 8 dígitos; con 6 son los últimos 6
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		code, err := TOTPCode(rfc6238Secret, tt.unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+		if code != tt.code {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestTOTPCodeRejectsInvalidSecret(t *testing.T) {
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("TOTPCode accepted an invalid secret")
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name   string
+		offset int64
+		valid  bool
+	}{
+		{"previous step", -1, true},
+		{"current step", 0, true},
+		{"next step", 1, true},
+		{"two steps behind", -2, false},
+		{"two steps ahead", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := TOTPCode(rfc6238Secret, step+tt.offset)
+			matched, ok := ValidateTOTP(rfc6238Secret, code, now)
+			if ok != tt.valid {
+				t.Fatalf("ValidateTOTP ok = %v, want %v", ok, tt.valid)
+			}
+			if ok && matched != step+tt.offset {
+				t.Errorf("matched step = %d, want %d", matched, step+tt.offset)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPAcceptsSpacesAndRejectsBadLength(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	if _, ok := ValidateTOTP(rfc6238Secret, " 050 471 ", now); !ok {
+		t.Error("code with spaces was rejected")
+	}
+	for _, code := range []string{"", "05047", "0504711"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, now); ok {
+			t.Errorf("code %q was accepted", code)
+		}
+	}
+}
+
+func TestValidateTOTPAfterRejectsReplay(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	code, _ := TOTPCode(rfc6238Secret, current)
+
+	step, ok := ValidateTOTPAfter(rfc6238Secret, code, now, 0)
+	if !ok || step != current {
+		t.Fatalf("first use: step = %d ok = %v", step, ok)
+	}
+	// totp_last_step guarda el paso aceptado: el mismo código ya no sirve
+	if _, ok := ValidateTOTPAfter(rfc6238Secret, code, now, step); ok {
+		t.Error("replayed code was accepted")
+	}
+	// Tampoco un código anterior dentro de la tolerancia
+	previous, _ := TOTPCode(rfc6238Secret, current-1)
+	if _, ok := ValidateTOTPAfter(rfc6238Secret, previous, now, step); ok {
+		t.Error("code older than the last used step was accepted")
+	}
+	// El siguiente paso sí
+	next, _ := TOTPCode(rfc6238Secret, current+1)
+	if _, ok := ValidateTOTPAfter(rfc6238Secret, next, now, step); !ok {
+		t.Error("code for a later step was rejected")
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes: %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("codes = %d, want 10", len(codes))
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q does not have the xxxxx-xxxxx format", code)
+		}
+		for _, symbol := range strings.ReplaceAll(code, "-", "") {
+			if !strings.ContainsRune(recoveryCodeAlphabet, symbol) {
+				t.Errorf("code %q has symbol %q outside the alphabet", code, symbol)
+			}
+		}
+		if seen[code] {
+			t.Errorf("code %q is repeated", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestRandomSymbolIsUniform(t *testing.T) {
+	const draws = 310000
+	counts := map[byte]int{}
+	for i := 0; i < draws; i++ {
+		symbol, err := randomSymbol(recoveryCodeAlphabet)
+		if err != nil {
+			t.Fatalf("randomSymbol: %v", err)
+		}
+		counts[symbol]++
+	}
+
+	// Con el módulo sesgado los primeros 8 símbolos salían un 12% más; ±5% son unos 5 desvíos
+	expected := draws / len(recoveryCodeAlphabet)
+	for i := 0; i < len(recoveryCodeAlphabet); i++ {
+		count := counts[recoveryCodeAlphabet[i]]
+		if count < expected*95/100 || count > expected*105/100 {
+			t.Errorf("symbol %q drawn %d times, want about %d", recoveryCodeAlphabet[i], count, expected)
+		}
+	}
+}
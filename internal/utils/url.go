@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"os"
+	"strings"
+)
+
+const defaultBaseURL = "http://localhost:8080"
+
+// PublicURL arma un enlace absoluto a partir de APP_BASE_URL, la URL pública de la aplicación
+func PublicURL(path string) string {
+	baseURL := strings.TrimRight(os.Getenv("APP_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return baseURL + path
+}
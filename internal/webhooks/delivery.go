@@ -0,0 +1,197 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/jobs"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/notifications"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+)
+
+const deliveryTimeout = 10 * time.Second
+
+// maxLoggedResponse es cuánto de la respuesta del endpoint se guarda en el registro
+const maxLoggedResponse = 2 << 10
+
+var errBlockedAddress = errors.New("webhook address is not allowed")
+
+// allowPrivateNetworks permite endpoints en la red local (WEBHOOK_ALLOW_PRIVATE_NETWORKS=true), para desarrollo
+func allowPrivateNetworks() bool {
+	return os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true"
+}
+
+// ValidateURL exige HTTPS (HTTP solo fuera de producción) y un host; las IP privadas se
+// rechazan al conectar, después de resolver el nombre
+func ValidateURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" || parsed.User != nil {
+		return errors.New("url must be an absolute URL without credentials")
+	}
+	if parsed.Scheme != "https" && (parsed.Scheme != "http" || os.Getenv("GIN_MODE") == "release") {
+		return errors.New("url must use https")
+	}
+	return nil
+}
+
+// sharedAddressSpace es el rango de CGNAT (RFC 6598), que muchas nubes usan para su red interna
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// checkDialAddress se ejecuta con la IP ya resuelta, justo antes de conectar: así un nombre que
+// resuelve a una dirección interna tampoco pasa
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	if allowPrivateNetworks() {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() || sharedAddressSpace.Contains(ip) {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+// client no sigue redirecciones y se niega a conectarse a direcciones internas, para que un
+// webhook no sirva para llegar a servicios de nuestra red
+var client = &http.Client{
+	Timeout: deliveryTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: checkDialAddress,
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: deliveryTimeout,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func deliver(ctx context.Context, job *jobs.Job) error {
+	var payload deliveryPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	delivery, err := models.GetWebhookDelivery(payload.DeliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery == nil {
+		return nil
+	}
+	webhook, err := models.GetWebhookByID(delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+	// El webhook se borró; su registro de entregas también
+	if webhook == nil {
+		return nil
+	}
+
+	if !webhook.Active && delivery.EventType != EventTest {
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = "webhook is disabled"
+		return models.RecordDeliveryAttempt(delivery)
+	}
+
+	delivery.Error = ""
+	delivery.ResponseStatus, delivery.ResponseBody, delivery.DurationMS, err = post(ctx, webhook, delivery)
+	if err == nil && (delivery.ResponseStatus < 200 || delivery.ResponseStatus >= 300) {
+		err = fmt.Errorf("endpoint responded %d", delivery.ResponseStatus)
+	}
+
+	if err == nil {
+		delivery.Status = models.DeliverySucceeded
+		if err := models.RecordDeliveryAttempt(delivery); err != nil {
+			return err
+		}
+		if delivery.EventType == EventTest {
+			return nil
+		}
+		return models.RecordWebhookSuccess(webhook.ID)
+	}
+
+	// El último intento deja la entrega como fallida; los anteriores la dejan pendiente del reintento
+	final := job.Attempts >= job.MaxAttempts
+	delivery.Status = models.DeliveryPending
+	if final {
+		delivery.Status = models.DeliveryFailed
+	}
+	delivery.Error = err.Error()
+	if recordErr := models.RecordDeliveryAttempt(delivery); recordErr != nil {
+		return recordErr
+	}
+
+	if final && delivery.EventType != EventTest {
+		limit := maxFailures()
+		reason := fmt.Sprintf("%d consecutive deliveries failed, last error: %s", limit, delivery.Error)
+		disabled, recordErr := models.RecordWebhookFailure(webhook.ID, limit, reason)
+		if recordErr != nil {
+			log.Printf("Error recording failure of webhook %s: %v", webhook.ID, recordErr)
+		}
+		if disabled {
+			notifyDisabled(webhook, limit)
+		}
+	}
+
+	return err
+}
+
+// post envía la entrega firmada; devuelve el status, el comienzo de la respuesta y la duración
+func post(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, string, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "restapi-go-webhooks/1.0")
+	req.Header.Set("X-Webhook-Id", delivery.ID)
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, time.Now(), delivery.Payload))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	duration := int(time.Since(start).Milliseconds())
+	if err != nil {
+		return 0, "", duration, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedResponse))
+	return resp.StatusCode, string(body), duration, nil
+}
+
+func notifyDisabled(webhook *models.Webhook, failures int) {
+	owner, err := models.GetUserByID(webhook.UserID)
+	if err != nil || owner == nil {
+		return
+	}
+
+	err = notifications.SendEmail(database.DB, notifications.Email{
+		To:             owner.Email,
+		Locale:         owner.Locale,
+		Template:       "webhook_disabled",
+		Data:           map[string]interface{}{"URL": webhook.URL, "Failures": failures},
+		IdempotencyKey: fmt.Sprintf("webhook_disabled:%s:%d", webhook.ID, time.Now().Unix()),
+	})
+	if err != nil {
+		log.Printf("Error notifying owner of disabled webhook %s: %v", webhook.ID, err)
+	}
+}
@@ -0,0 +1,141 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+)
+
+func TestCheckDialAddress(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "")
+
+	tests := []struct {
+		name    string
+		address string
+		blocked bool
+	}{
+		{"loopback", "127.0.0.1:443", true},
+		{"loopback IPv6", "[::1]:443", true},
+		{"private 10/8", "10.0.0.5:443", true},
+		{"private 172.16/12", "172.16.3.4:443", true},
+		{"private 192.168/16", "192.168.1.1:443", true},
+		{"private IPv6", "[fd00::1]:443", true},
+		{"CGNAT", "100.64.0.1:443", true},
+		{"CGNAT upper bound", "100.127.255.254:443", true},
+		{"link-local (cloud metadata)", "169.254.169.254:80", true},
+		{"link-local IPv6", "[fe80::1]:443", true},
+		{"unspecified", "0.0.0.0:443", true},
+		{"multicast", "224.0.0.1:443", true},
+		{"public", "93.184.216.34:443", false},
+		{"public next to CGNAT", "100.128.0.1:443", false},
+		{"public IPv6", "[2606:4700::1111]:443", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDialAddress("tcp", tt.address, nil)
+			if tt.blocked && !errors.Is(err, errBlockedAddress) {
+				t.Errorf("err = %v, want errBlockedAddress", err)
+			}
+			if !tt.blocked && err != nil {
+				t.Errorf("err = %v, want nil", err)
+			}
+		})
+	}
+
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "true")
+	if err := checkDialAddress("tcp", "10.0.0.5:443", nil); err != nil {
+		t.Errorf("with WEBHOOK_ALLOW_PRIVATE_NETWORKS err = %v, want nil", err)
+	}
+}
+
+// signedServer es un endpoint en 127.0.0.1 que cuenta las entregas recibidas
+func signedServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func testDelivery() (*models.Webhook, *models.WebhookDelivery) {
+	webhook := &models.Webhook{ID: "webhook-1", Secret: "whsec_test"}
+	delivery := &models.WebhookDelivery{ID: "delivery-1", EventType: EventTest, Payload: []byte(`{"id":"delivery-1"}`)}
+	return webhook, delivery
+}
+
+func TestPostRefusesLoopbackEndpoint(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "")
+	server, requests := signedServer(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	webhook, delivery := testDelivery()
+	webhook.URL = server.URL
+	_, _, _, err := post(context.Background(), webhook, delivery)
+	if !errors.Is(err, errBlockedAddress) {
+		t.Errorf("post err = %v, want errBlockedAddress", err)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("endpoint received %d requests, want 0", requests.Load())
+	}
+}
+
+func TestPostSignsDelivery(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "true")
+	webhook, delivery := testDelivery()
+
+	server, _ := signedServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Webhook-Id") != delivery.ID || r.Header.Get("X-Webhook-Event") != delivery.EventType {
+			t.Errorf("headers = %v", r.Header)
+		}
+
+		// Verificación como la haría el receptor: recalcular con el timestamp recibido
+		signature := r.Header.Get(SignatureHeader)
+		timestamp, _, found := strings.Cut(strings.TrimPrefix(signature, "t="), ",")
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if !found || err != nil {
+			t.Errorf("malformed signature header %q", signature)
+			return
+		}
+		if time.Since(time.Unix(unix, 0)) > time.Minute {
+			t.Errorf("signature timestamp %d is not current", unix)
+		}
+		if want := Sign(webhook.Secret, time.Unix(unix, 0), delivery.Payload); signature != want {
+			t.Errorf("signature = %s, want %s", signature, want)
+		}
+		w.Write([]byte("ok"))
+	})
+	webhook.URL = server.URL
+
+	status, body, _, err := post(context.Background(), webhook, delivery)
+	if err != nil || status != http.StatusOK || body != "ok" {
+		t.Errorf("post = %d %q %v", status, body, err)
+	}
+}
+
+func TestPostDoesNotFollowRedirects(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "true")
+	target, requests := signedServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	server, _ := signedServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	})
+
+	webhook, delivery := testDelivery()
+	webhook.URL = server.URL
+	status, _, _, err := post(context.Background(), webhook, delivery)
+	if err != nil || status != http.StatusTemporaryRedirect {
+		t.Errorf("post = %d %v, want the redirect as the response", status, err)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("redirect target received %d requests, want 0", requests.Load())
+	}
+}
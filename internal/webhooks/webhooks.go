@@ -0,0 +1,190 @@
+// Package webhooks avisa a los sistemas de los organizadores de lo que pasa en sus eventos.
+// Cada aviso queda en el registro de entregas y se envía firmado a través de la cola de trabajos.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/jobs"
+	"github.com/AgusMolinaCode/restApi-Go.git/internal/models"
+	"github.com/AgusMolinaCode/restApi-Go.git/pkg/database"
+	"github.com/google/uuid"
+)
+
+const (
+	EventRegistrationCreated   = "registration.created"
+	EventRegistrationCancelled = "registration.cancelled"
+	EventEventUpdated          = "event.updated"
+	EventEventStatusChanged    = "event.status_changed"
+	// EventTest solo se envía desde el endpoint de prueba, no hace falta suscribirse
+	EventTest = "webhook.test"
+)
+
+var EventTypes = []string{EventRegistrationCreated, EventRegistrationCancelled, EventEventUpdated, EventEventStatusChanged}
+
+const JobDeliverWebhook = "deliver_webhook"
+
+// SignatureHeader lleva "t=<unix>,v1=<hex>": el HMAC-SHA256 con el secreto del webhook de "<t>.<cuerpo>".
+// El receptor debe recalcularlo y rechazar timestamps viejos para evitar reenvíos.
+const SignatureHeader = "X-Webhook-Signature"
+
+// Envelope es el cuerpo que recibe el endpoint
+type Envelope struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+type deliveryPayload struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+func IsEventType(name string) bool {
+	for _, eventType := range EventTypes {
+		if eventType == name {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSecret genera el secreto con el que se firman las entregas de un webhook
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatch encola el aviso para cada webhook activo del usuario suscripto a ese tipo de evento;
+// exec debe ser la transacción del cambio que lo origina
+func Dispatch(exec database.Executor, userID, eventType string, data interface{}) error {
+	webhooks, err := models.GetActiveWebhooksFor(exec, userID, eventType)
+	if err != nil {
+		return err
+	}
+
+	for i := range webhooks {
+		if _, err := enqueue(exec, &webhooks[i], eventType, data, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SendTest encola una entrega de prueba sin reintentos, aunque el webhook esté desactivado
+func SendTest(webhook *models.Webhook) (*models.WebhookDelivery, error) {
+	data := map[string]interface{}{
+		"webhook_id": webhook.ID,
+		"message":    "This is a test delivery",
+	}
+	return enqueue(database.DB, webhook, EventTest, data, 1)
+}
+
+func enqueue(exec database.Executor, webhook *models.Webhook, eventType string, data interface{}, maxAttempts int) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{
+		ID:        uuid.New().String(),
+		WebhookID: webhook.ID,
+		EventType: eventType,
+		CreatedAt: time.Now(),
+	}
+
+	payload, err := json.Marshal(Envelope{ID: delivery.ID, Type: eventType, CreatedAt: delivery.CreatedAt, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = payload
+
+	if err := delivery.Save(exec); err != nil {
+		return nil, err
+	}
+
+	err = jobs.Enqueue(exec, jobs.NewJob{
+		Type:           JobDeliverWebhook,
+		Payload:        deliveryPayload{DeliveryID: delivery.ID},
+		IdempotencyKey: "webhook_delivery:" + delivery.ID,
+		MaxAttempts:    maxAttempts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// maxFailures lee WEBHOOK_MAX_FAILURES: entregas seguidas que agotan sus reintentos antes de desactivar el webhook
+func maxFailures() int {
+	if value, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_FAILURES")); err == nil && value > 0 {
+		return value
+	}
+	return 5
+}
+
+// logRetention lee WEBHOOK_LOG_RETENTION, cuánto se guarda el registro de entregas
+func logRetention() time.Duration {
+	if value, err := time.ParseDuration(os.Getenv("WEBHOOK_LOG_RETENTION")); err == nil && value > 0 {
+		return value
+	}
+	return 30 * 24 * time.Hour
+}
+
+// Init registra el handler de entregas y la limpieza periódica del registro
+func Init() {
+	jobs.Register(JobDeliverWebhook, deliver)
+
+	go func() {
+		for {
+			cutoff := time.Now().Add(-logRetention())
+			if deleted, err := models.DeleteWebhookDeliveriesBefore(cutoff); err != nil {
+				log.Printf("Error cleaning up webhook deliveries: %v", err)
+			} else if deleted > 0 {
+				log.Printf("Deleted %d old webhook deliveries", deleted)
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+}
+
+// RegistrationData arma el aviso de una inscripción con los datos de contacto que el usuario
+// comparte con los organizadores, igual que la lista de inscriptos
+func RegistrationData(registration *models.Registration, event *models.Event, attendee *models.UserResponse) map[string]interface{} {
+	detail := models.RegistrationDetail{
+		UserID:    attendee.ID,
+		Username:  attendee.Username,
+		CreatedAt: registration.CreatedAt,
+	}
+	if attendee.Privacy.ShareEmailWithOrganizers {
+		detail.Email = attendee.Email
+	}
+	if attendee.Privacy.ShareWhatsappWithOrganizers {
+		detail.Whatsapp = attendee.Whatsapp
+	}
+
+	return map[string]interface{}{
+		"registration_id": registration.ID,
+		"event_id":        event.ID,
+		"event_name":      event.Name,
+		"event_date":      registration.EventDate,
+		"attendee":        detail,
+	}
+}
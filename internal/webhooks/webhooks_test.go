@@ -0,0 +1,29 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"delivery-1"}`)
+	timestamp := time.Unix(1700000000, 0)
+
+	// HMAC-SHA256 de "1700000000.{"id":"delivery-1"}" con el secreto, calculado aparte
+	want := "t=1700000000,v1=b6592bf4dc09b082a2fad21eb91d6e7965ac3c90e7a3fb238949df03499da1c0"
+	if got := Sign("whsec_test", timestamp, body); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+
+	// Cambiar cualquiera de las partes firmadas cambia la firma
+	variants := map[string]string{
+		"secret":    Sign("whsec_other", timestamp, body),
+		"timestamp": Sign("whsec_test", timestamp.Add(time.Second), body),
+		"body":      Sign("whsec_test", timestamp, []byte(`{"id":"delivery-2"}`)),
+	}
+	for name, signature := range variants {
+		if signature == want {
+			t.Errorf("changing the %s kept the same signature", name)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultCloudURL = "https://graph.facebook.com/v19.0"
+
+// CloudProvider usa la API de WhatsApp Business Cloud
+type CloudProvider struct {
+	BaseURL       string
+	PhoneNumberID string
+	AccessToken   string
+	Client        *http.Client
+}
+
+type cloudParameter struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type cloudComponent struct {
+	Type       string           `json:"type"`
+	Parameters []cloudParameter `json:"parameters"`
+}
+
+type cloudMessage struct {
+	MessagingProduct string `json:"messaging_product"`
+	To               string `json:"to"`
+	Type             string `json:"type"`
+	Template         struct {
+		Name     string `json:"name"`
+		Language struct {
+			Code string `json:"code"`
+		} `json:"language"`
+		Components []cloudComponent `json:"components,omitempty"`
+	} `json:"template"`
+}
+
+func (p *CloudProvider) SendTemplate(ctx context.Context, to, template, language string, params []string) (string, error) {
+	var message cloudMessage
+	message.MessagingProduct = "whatsapp"
+	// La API espera el número sin el "+"
+	message.To = strings.TrimPrefix(to, "+")
+	message.Type = "template"
+	message.Template.Name = template
+	message.Template.Language.Code = language
+	if len(params) > 0 {
+		component := cloudComponent{Type: "body"}
+		for _, param := range params {
+			component.Parameters = append(component.Parameters, cloudParameter{Type: "text", Text: param})
+		}
+		message.Template.Components = []cloudComponent{component}
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+
+	url := strings.TrimRight(p.BaseURL, "/") + "/" + p.PhoneNumberID + "/messages"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	// 4xx (salvo 429) son errores del mensaje: número inválido, plantilla inexistente, etc.
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return "", fmt.Errorf("%w: status %d: %s", ErrRejected, resp.StatusCode, responseBody)
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("whatsapp: status %d: %s", resp.StatusCode, responseBody)
+	}
+
+	var result struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return "", err
+	}
+	if len(result.Messages) == 0 {
+		return "", fmt.Errorf("whatsapp: response without message id")
+	}
+
+	return result.Messages[0].ID, nil
+}
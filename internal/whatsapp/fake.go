@@ -0,0 +1,70 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type FakeMessage struct {
+	ID       string    `json:"id"`
+	To       string    `json:"to"`
+	Template string    `json:"template"`
+	Language string    `json:"language"`
+	Params   []string  `json:"params"`
+	SentAt   time.Time `json:"sent_at"`
+}
+
+// FakeProvider guarda los mensajes en memoria; sirve para tests y desarrollo local
+type FakeProvider struct {
+	mu       sync.Mutex
+	messages []FakeMessage
+}
+
+func (p *FakeProvider) SendTemplate(ctx context.Context, to, template, language string, params []string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	message := FakeMessage{
+		ID:       fmt.Sprintf("fake-%d", len(p.messages)+1),
+		To:       to,
+		Template: template,
+		Language: language,
+		Params:   params,
+		SentAt:   time.Now(),
+	}
+	p.messages = append(p.messages, message)
+	return message.ID, nil
+}
+
+func (p *FakeProvider) Messages() []FakeMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]FakeMessage(nil), p.messages...)
+}
+
+// ServeHTTP lista los mensajes enviados (GET, filtrables con ?to=) o los borra (DELETE)
+func (p *FakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		to := r.URL.Query().Get("to")
+		messages := []FakeMessage{}
+		for _, message := range p.Messages() {
+			if to == "" || message.To == to {
+				messages = append(messages, message)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"messages": messages})
+	case http.MethodDelete:
+		p.mu.Lock()
+		p.messages = nil
+		p.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
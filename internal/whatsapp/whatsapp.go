@@ -0,0 +1,55 @@
+// Package whatsapp envía mensajes de plantilla por WhatsApp. Las plantillas tienen que estar
+// aprobadas en la cuenta de WhatsApp Business con los mismos nombres y parámetros posicionales.
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+)
+
+type Provider interface {
+	// SendTemplate envía la plantilla en el idioma indicado y devuelve el ID del mensaje
+	SendTemplate(ctx context.Context, to, template, language string, params []string) (string, error)
+}
+
+// Default es nil si WhatsApp no está configurado; en ese caso solo se usa el email
+var Default Provider
+
+// Outbox lista los mensajes del proveedor falso en /dev/whatsapp cuando WHATSAPP_PROVIDER=fake
+var Outbox http.Handler
+
+// ErrRejected indica que el proveedor rechazó el mensaje y reintentarlo no va a cambiar el resultado
+var ErrRejected = errors.New("whatsapp: message rejected")
+
+func Init() {
+	switch os.Getenv("WHATSAPP_PROVIDER") {
+	case "":
+		return
+	case "cloud":
+		provider := &CloudProvider{
+			BaseURL:       os.Getenv("WHATSAPP_API_URL"),
+			PhoneNumberID: os.Getenv("WHATSAPP_PHONE_NUMBER_ID"),
+			AccessToken:   os.Getenv("WHATSAPP_ACCESS_TOKEN"),
+		}
+		if provider.BaseURL == "" {
+			provider.BaseURL = defaultCloudURL
+		}
+		if provider.PhoneNumberID == "" || provider.AccessToken == "" {
+			log.Fatal("WHATSAPP_PHONE_NUMBER_ID and WHATSAPP_ACCESS_TOKEN are required when WHATSAPP_PROVIDER=cloud")
+		}
+		Default = provider
+	case "fake":
+		if os.Getenv("GIN_MODE") == "release" {
+			log.Fatal("WHATSAPP_PROVIDER=fake cannot be used in release mode")
+		}
+		fake := &FakeProvider{}
+		Default = fake
+		Outbox = fake
+		log.Printf("WhatsApp messages will be kept in memory and listed at /dev/whatsapp")
+	default:
+		log.Fatalf("Unknown WHATSAPP_PROVIDER %q (use cloud or fake)", os.Getenv("WHATSAPP_PROVIDER"))
+	}
+}
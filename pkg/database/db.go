@@ -11,21 +11,22 @@ import (
 
 var DB *sql.DB
 
-func InitDB() {
-	var err error
-	var connStr string
-
+// ConnectionString arma la conexión a Postgres; también la usan las conexiones dedicadas, como LISTEN
+func ConnectionString() string {
 	// Intentar usar DATABASE_URL primero (proporcionado por Railway)
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL != "" {
-		connStr = databaseURL
-	} else {
-		// Si no hay DATABASE_URL, usar variables individuales
-		connStr = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-			os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"))
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		return databaseURL
 	}
 
-	DB, err = sql.Open("postgres", connStr)
+	// Si no hay DATABASE_URL, usar variables individuales
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"))
+}
+
+func InitDB() {
+	var err error
+
+	DB, err = sql.Open("postgres", ConnectionString())
 	if err != nil {
 		log.Fatalf("Error opening database: %v", err)
 	}
@@ -101,6 +102,266 @@ func createTables() {
 		);
 	`
 
+	alterUsersTable := `
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN DEFAULT FALSE;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT DEFAULT 0;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name TEXT;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_url TEXT;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT DEFAULT 'user';
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS share_email_with_organizers BOOLEAN DEFAULT TRUE;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS share_whatsapp_with_organizers BOOLEAN DEFAULT TRUE;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email TEXT;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS email_change_token TEXT;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS email_change_expiry TIMESTAMP;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_requested_at TIMESTAMPTZ;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_for TIMESTAMPTZ;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_transfer_to TEXT;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_transfer_accepted_at TIMESTAMPTZ;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS reset_token_hash TEXT;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS locale TEXT DEFAULT 'es';
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS notification_channels TEXT[] DEFAULT '{email}';
+		-- Los tokens antiguos se guardaban en texto plano; se invalidan
+		UPDATE users SET reset_token = NULL, reset_token_expiry = NULL WHERE reset_token IS NOT NULL AND reset_token_hash IS NULL;
+	`
+
+	createRecoveryCodesTable := `
+		CREATE TABLE IF NOT EXISTS recovery_codes (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			code_hash TEXT NOT NULL,
+			used_at TEXT,
+			created_at TEXT NOT NULL,
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		);
+	`
+
+	createLoginAttemptsTable := `
+		CREATE TABLE IF NOT EXISTS login_attempts (
+			key TEXT PRIMARY KEY,
+			count INTEGER NOT NULL,
+			last_failure TIMESTAMPTZ NOT NULL,
+			locked_until TIMESTAMPTZ
+		);
+	`
+
+	createAPIKeysTable := `
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			prefix TEXT NOT NULL,
+			key_hash TEXT NOT NULL UNIQUE,
+			scopes TEXT[] NOT NULL,
+			created_at TEXT NOT NULL,
+			last_used_at TIMESTAMPTZ,
+			revoked_at TIMESTAMPTZ,
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		);
+	`
+
+	// Los usuarios que entran con un proveedor externo pueden no tener WhatsApp
+	alterUsersWhatsapp := `ALTER TABLE users ALTER COLUMN whatsapp DROP NOT NULL;`
+
+	// Las inscripciones de cuentas borradas se conservan anonimizadas
+	// Una inscripción por usuario y evento: antes del índice se conserva la primera de las repetidas.
+	// Las anonimizadas (user_id NULL) no chocan entre sí.
+	alterRegistrationsTable := `
+		ALTER TABLE registrations ALTER COLUMN user_id DROP NOT NULL;
+		DELETE FROM registrations r USING registrations d
+		WHERE r.event_id = d.event_id AND r.user_id = d.user_id AND (r.created_at, r.id) > (d.created_at, d.id);
+		CREATE UNIQUE INDEX IF NOT EXISTS registrations_event_user_idx ON registrations (event_id, user_id);
+	`
+
+	alterEventsTable := `
+		ALTER TABLE events ADD COLUMN IF NOT EXISTS archived_at TEXT;
+		ALTER TABLE events ADD COLUMN IF NOT EXISTS outdoor BOOLEAN NOT NULL DEFAULT FALSE;
+		ALTER TABLE events ADD COLUMN IF NOT EXISTS weather_alerts_attendees BOOLEAN NOT NULL DEFAULT FALSE;
+		ALTER TABLE events ADD COLUMN IF NOT EXISTS location_city TEXT;
+		ALTER TABLE events ADD COLUMN IF NOT EXISTS location_province TEXT;
+		CREATE INDEX IF NOT EXISTS events_location_city_idx ON events (LOWER(location_city));
+	`
+
+	createOIDCStatesTable := `
+		CREATE TABLE IF NOT EXISTS oidc_states (
+			state TEXT PRIMARY KEY,
+			code_verifier TEXT NOT NULL,
+			nonce TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL
+		);
+	`
+
+	createUserIdentitiesTable := `
+		CREATE TABLE IF NOT EXISTS user_identities (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			issuer TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			email TEXT,
+			created_at TEXT NOT NULL,
+			UNIQUE(issuer, subject),
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		);
+	`
+
+	createJobsTable := `
+		CREATE TABLE IF NOT EXISTS jobs (
+			id TEXT PRIMARY KEY,
+			type TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			status TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			max_attempts INTEGER NOT NULL,
+			run_at TIMESTAMPTZ NOT NULL,
+			locked_until TIMESTAMPTZ,
+			last_error TEXT,
+			idempotency_key TEXT UNIQUE,
+			sensitive BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			completed_at TIMESTAMPTZ
+		);
+		CREATE INDEX IF NOT EXISTS jobs_status_run_at_idx ON jobs (status, run_at);
+	`
+
+	createNotificationsTable := `
+		CREATE TABLE IF NOT EXISTS notifications (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			type TEXT,
+			template TEXT NOT NULL,
+			title TEXT NOT NULL,
+			data JSONB NOT NULL,
+			read_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL,
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		);
+		CREATE INDEX IF NOT EXISTS notifications_user_created_idx ON notifications (user_id, created_at DESC);
+	`
+
+	createWebhooksTable := `
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			url TEXT NOT NULL,
+			event_types TEXT[] NOT NULL,
+			secret TEXT NOT NULL,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			consecutive_failures INTEGER NOT NULL DEFAULT 0,
+			disabled_at TIMESTAMPTZ,
+			disabled_reason TEXT,
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		);
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id TEXT PRIMARY KEY,
+			webhook_id TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			status TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			response_status INTEGER,
+			response_body TEXT,
+			error TEXT,
+			duration_ms INTEGER,
+			created_at TIMESTAMPTZ NOT NULL,
+			last_attempt_at TIMESTAMPTZ,
+			FOREIGN KEY(webhook_id) REFERENCES webhooks(id)
+		);
+		CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_created_idx ON webhook_deliveries (webhook_id, created_at DESC);
+	`
+
+	// Solo se guardan las preferencias que el usuario cambió; el resto usa los canales por defecto
+	createNotificationPreferencesTable := `
+		CREATE TABLE IF NOT EXISTS notification_preferences (
+			user_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			channel TEXT NOT NULL,
+			enabled BOOLEAN NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY(user_id, type, channel),
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		);
+	`
+
+	// Historial de las revisiones del pronóstico de los eventos al aire libre
+	createWeatherChecksTable := `
+		CREATE TABLE IF NOT EXISTS weather_checks (
+			id TEXT PRIMARY KEY,
+			event_id TEXT NOT NULL,
+			event_date TEXT NOT NULL,
+			status TEXT NOT NULL,
+			forecast_at TIMESTAMPTZ,
+			temperature DOUBLE PRECISION,
+			precipitation_probability DOUBLE PRECISION,
+			rain_mm DOUBLE PRECISION,
+			alerts TEXT[] NOT NULL,
+			checked_at TIMESTAMPTZ NOT NULL,
+			FOREIGN KEY(event_id) REFERENCES events(id)
+		);
+		CREATE INDEX IF NOT EXISTS weather_checks_event_checked_idx ON weather_checks (event_id, checked_at DESC);
+		CREATE INDEX IF NOT EXISTS weather_checks_checked_idx ON weather_checks (checked_at);
+	`
+
+	// Lugares reutilizables; user_id queda vacío si se borra la cuenta de quien lo cargó
+	createVenuesTable := `
+		CREATE TABLE IF NOT EXISTS venues (
+			id TEXT PRIMARY KEY,
+			user_id TEXT,
+			name TEXT NOT NULL,
+			capacity INTEGER NOT NULL DEFAULT 0,
+			address TEXT NOT NULL,
+			lat DOUBLE PRECISION NOT NULL,
+			lng DOUBLE PRECISION NOT NULL,
+			city TEXT,
+			province TEXT,
+			exclusive_parking BOOLEAN NOT NULL DEFAULT FALSE,
+			accessibility TEXT[] NOT NULL DEFAULT '{}',
+			transport_guide TEXT,
+			photos TEXT[] NOT NULL DEFAULT '{}',
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		);
+		ALTER TABLE events ADD COLUMN IF NOT EXISTS venue_id TEXT REFERENCES venues(id);
+		CREATE INDEX IF NOT EXISTS events_venue_idx ON events (venue_id);
+	`
+
+	// Perfil público de organizador; verified solo lo cambia un administrador
+	createOrganizersTables := `
+		CREATE TABLE IF NOT EXISTS organizer_profiles (
+			user_id TEXT PRIMARY KEY,
+			name TEXT,
+			bio TEXT,
+			logo_url TEXT,
+			social_links JSONB,
+			verified BOOLEAN NOT NULL DEFAULT FALSE,
+			verified_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		);
+		CREATE TABLE IF NOT EXISTS follows (
+			follower_id TEXT NOT NULL,
+			organizer_id TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY(follower_id, organizer_id),
+			FOREIGN KEY(follower_id) REFERENCES users(id),
+			FOREIGN KEY(organizer_id) REFERENCES users(id)
+		);
+		CREATE INDEX IF NOT EXISTS follows_organizer_idx ON follows (organizer_id);
+	`
+
+	// Ciclo de vida del evento; los que ya existían quedan publicados salvo los archivados al borrar cuentas
+	alterEventsStatus := `
+		ALTER TABLE events ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'published';
+		ALTER TABLE events ADD COLUMN IF NOT EXISTS publish_at TIMESTAMPTZ;
+		ALTER TABLE events ADD COLUMN IF NOT EXISTS status_reason TEXT;
+		UPDATE events SET status = 'archived' WHERE archived_at IS NOT NULL AND status <> 'archived';
+		CREATE INDEX IF NOT EXISTS events_status_idx ON events (status);
+	`
+
 	_, err := DB.Exec(createEventsTable)
 	if err != nil {
 		log.Fatalf("Error creating events table: %v", err)
@@ -115,4 +376,89 @@ func createTables() {
 	if err != nil {
 		log.Fatalf("Error creating registrations table: %v", err)
 	}
+
+	_, err = DB.Exec(alterUsersTable)
+	if err != nil {
+		log.Fatalf("Error altering users table: %v", err)
+	}
+
+	_, err = DB.Exec(createRecoveryCodesTable)
+	if err != nil {
+		log.Fatalf("Error creating recovery_codes table: %v", err)
+	}
+
+	_, err = DB.Exec(createLoginAttemptsTable)
+	if err != nil {
+		log.Fatalf("Error creating login_attempts table: %v", err)
+	}
+
+	_, err = DB.Exec(createAPIKeysTable)
+	if err != nil {
+		log.Fatalf("Error creating api_keys table: %v", err)
+	}
+
+	_, err = DB.Exec(alterUsersWhatsapp)
+	if err != nil {
+		log.Fatalf("Error altering users table: %v", err)
+	}
+
+	_, err = DB.Exec(alterRegistrationsTable)
+	if err != nil {
+		log.Fatalf("Error altering registrations table: %v", err)
+	}
+
+	_, err = DB.Exec(alterEventsTable)
+	if err != nil {
+		log.Fatalf("Error altering events table: %v", err)
+	}
+
+	_, err = DB.Exec(createOIDCStatesTable)
+	if err != nil {
+		log.Fatalf("Error creating oidc_states table: %v", err)
+	}
+
+	_, err = DB.Exec(createUserIdentitiesTable)
+	if err != nil {
+		log.Fatalf("Error creating user_identities table: %v", err)
+	}
+
+	_, err = DB.Exec(createJobsTable)
+	if err != nil {
+		log.Fatalf("Error creating jobs table: %v", err)
+	}
+
+	_, err = DB.Exec(createNotificationsTable)
+	if err != nil {
+		log.Fatalf("Error creating notifications table: %v", err)
+	}
+
+	_, err = DB.Exec(createWebhooksTable)
+	if err != nil {
+		log.Fatalf("Error creating webhooks tables: %v", err)
+	}
+
+	_, err = DB.Exec(createNotificationPreferencesTable)
+	if err != nil {
+		log.Fatalf("Error creating notification_preferences table: %v", err)
+	}
+
+	_, err = DB.Exec(createWeatherChecksTable)
+	if err != nil {
+		log.Fatalf("Error creating weather_checks table: %v", err)
+	}
+
+	_, err = DB.Exec(createVenuesTable)
+	if err != nil {
+		log.Fatalf("Error creating venues table: %v", err)
+	}
+
+	_, err = DB.Exec(createOrganizersTables)
+	if err != nil {
+		log.Fatalf("Error creating organizer tables: %v", err)
+	}
+
+	_, err = DB.Exec(alterEventsStatus)
+	if err != nil {
+		log.Fatalf("Error altering events table: %v", err)
+	}
 }
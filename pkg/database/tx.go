@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+)
+
+// Executor lo cumplen *sql.DB y *sql.Tx, para que una misma función sirva dentro o fuera de una transacción
+type Executor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// WithTx ejecuta fn en una transacción: confirma si fn termina sin error y la deshace en caso contrario
+func WithTx(fn func(tx *sql.Tx) error) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// WithAdvisoryLock ejecuta fn solo si consigue el advisory lock key de Postgres, para que una tarea
+// periódica corra en una sola réplica a la vez. Devuelve false si otra réplica lo tiene tomado.
+func WithAdvisoryLock(key int64, fn func()) (bool, error) {
+	ctx := context.Background()
+	// El lock es de la sesión: se toma y se suelta en la misma conexión
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&locked); err != nil || !locked {
+		return false, err
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, key); err != nil {
+			// Si no se pudo soltar, la conexión se descarta para no devolverla al pool con el lock tomado
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+	}()
+
+	fn()
+	return true, nil
+}